@@ -0,0 +1,128 @@
+package authz
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Add two values.
+//
+// Supported operand types are:
+//   - number + number = number; see arithNumbers
+//   - time + duration = time
+//   - duration + time = time
+//   - duration + duration = duration
+func addValues(left, right interface{}) (interface{}, error) {
+	if _, err := coerceNumber(left); err == nil {
+		return evalNumbers(left, right, addNumbers, "$add")
+	}
+
+	if lTime, err := coerceTime(left); err == nil {
+		rDuration, err := coerceDuration(right)
+		if err != nil {
+			return nil, fmt.Errorf("mismatched types for $add(): %T, %T", left, right)
+		}
+		return lTime.Add(rDuration), nil
+	}
+
+	if lDuration, err := coerceDuration(left); err == nil {
+		if rTime, err := coerceTime(right); err == nil {
+			return rTime.Add(lDuration), nil
+		}
+		rDuration, err := coerceDuration(right)
+		if err != nil {
+			return nil, fmt.Errorf("mismatched types for $add(): %T, %T", left, right)
+		}
+		return addDurations(lDuration, rDuration)
+	}
+
+	return nil, fmt.Errorf("unsupported type for $add(): %T", left)
+}
+
+// Subtract one value from another.
+//
+// Supported operand types are:
+//   - number - number = number; see arithNumbers
+//   - time - duration = time
+//   - time - time = duration
+//   - duration - duration = duration
+func subValues(left, right interface{}) (interface{}, error) {
+	if _, err := coerceNumber(left); err == nil {
+		return evalNumbers(left, right, subNumbers, "$sub")
+	}
+
+	if lTime, err := coerceTime(left); err == nil {
+		if rTime, err := coerceTime(right); err == nil {
+			d := lTime.Sub(rTime)
+			// Sub saturates rather than overflowing, so check the result
+			if !rTime.Add(d).Equal(lTime) {
+				return nil, errors.New("duration overflow in $sub()")
+			}
+			return d, nil
+		}
+		rDuration, err := coerceDuration(right)
+		if err != nil {
+			return nil, fmt.Errorf("mismatched types for $sub(): %T, %T", left, right)
+		}
+		return lTime.Add(-rDuration), nil
+	}
+
+	if lDuration, err := coerceDuration(left); err == nil {
+		rDuration, err := coerceDuration(right)
+		if err != nil {
+			return nil, fmt.Errorf("mismatched types for $sub(): %T, %T", left, right)
+		}
+		if rDuration == minDuration {
+			return nil, errors.New("duration overflow in $sub()")
+		}
+		return addDurations(lDuration, -rDuration)
+	}
+
+	return nil, fmt.Errorf("unsupported type for $sub(): %T", left)
+}
+
+// Multiply two numbers.
+func mulValues(left, right interface{}) (interface{}, error) {
+	return evalNumbers(left, right, mulNumbers, "$mul")
+}
+
+// Divide one number by another.
+func divValues(left, right interface{}) (interface{}, error) {
+	return evalNumbers(left, right, divNumbers, "$div")
+}
+
+// Get the remainder of dividing one number by another.
+func modValues(left, right interface{}) (interface{}, error) {
+	return evalNumbers(left, right, modNumbers, "$mod")
+}
+
+// Apply an arithmetic operation to two values, both of which must be numbers.
+func evalNumbers(left, right interface{}, fn func(a, b number) (number, error), op string) (interface{}, error) {
+	lNum, err := coerceNumber(left)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported type for %s(): %T", op, left)
+	}
+	rNum, err := coerceNumber(right)
+	if err != nil {
+		return nil, fmt.Errorf("mismatched types for %s(): %T, %T", op, left, right)
+	}
+
+	r, err := fn(lNum, rNum)
+	if err != nil {
+		return nil, fmt.Errorf("%w in %s()", err, op)
+	}
+	return r.value(), nil
+}
+
+// The smallest representable duration.
+const minDuration = time.Duration(-1 << 63)
+
+// Add two durations, failing on overflow.
+func addDurations(a, b time.Duration) (time.Duration, error) {
+	sum := a + b
+	if (b > 0 && sum < a) || (b < 0 && sum > a) {
+		return 0, errors.New("duration overflow")
+	}
+	return sum, nil
+}
@@ -0,0 +1,72 @@
+package authz
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+)
+
+// Addition and subtraction of times and durations works as expected.
+func TestTimeArithmetic(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	data := []struct {
+		op          string
+		left        interface{}
+		right       interface{}
+		want        interface{}
+		expectError error
+	}{
+		{"add", t0, time.Hour, t0.Add(time.Hour), nil},
+		{"add", time.Hour, t0, t0.Add(time.Hour), nil},
+		{"add", time.Hour, time.Minute, time.Hour + time.Minute, nil},
+		{"add", time.Duration(math.MaxInt64), time.Nanosecond, nil, errors.New("")},
+		{"add", t0, t0, nil, errors.New("")},
+		{"add", t0, "1h", nil, errors.New("")},
+		{"add", "foo", time.Hour, nil, errors.New("")},
+		{"sub", t0, time.Hour, t0.Add(-time.Hour), nil},
+		{"sub", t0.Add(time.Hour), t0, time.Hour, nil},
+		{"sub", time.Hour, time.Minute, 59 * time.Minute, nil},
+		{"sub", time.Duration(math.MinInt64), time.Nanosecond, nil, errors.New("")},
+		{"sub", time.Duration(0), time.Duration(math.MinInt64), nil, errors.New("")},
+		{"sub", t0.AddDate(300, 0, 0), t0, nil, errors.New("")},
+		{"sub", time.Hour, t0, nil, errors.New("")},
+	}
+	for _, d := range data {
+		t.Run(fmt.Sprintf("%s(%v, %v)", d.op, d.left, d.right), func(t *testing.T) {
+			var got interface{}
+			var err error
+			if d.op == "add" {
+				got, err = addValues(d.left, d.right)
+			} else {
+				got, err = subValues(d.left, d.right)
+			}
+
+			if err != nil {
+				if d.expectError == nil {
+					t.Fatalf("unexpected error: %v", err)
+				} else {
+					return
+				}
+			}
+
+			if d.expectError != nil {
+				if err == nil {
+					t.Fatalf("expected error: %v", d.expectError)
+				} else {
+					return
+				}
+			}
+
+			eq, err := compareEqual(got, d.want)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !eq {
+				t.Fatalf("got %v, want %v", got, d.want)
+			}
+		})
+	}
+}
@@ -6,3 +6,33 @@ type Evaluator struct{}
 func (i Evaluator) Eval(expr Expr, params map[string]interface{}) (interface{}, error) {
 	return expr.Eval(params)
 }
+
+// The key under which a child scope records its parent. The parent has an
+// unexported type, so it cannot be forged by a caller-supplied environment,
+// and it is never itself the value of a variable.
+const parentScopeKey = "$parent"
+
+type parentScope map[string]interface{}
+
+// Create a child scope of an environment, in which variables may be bound,
+// shadowing those of the same name, without affecting the environment.
+// Variables not bound in the child scope are looked up in the environment.
+func childScope(env map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{parentScopeKey: parentScope(env)}
+}
+
+// Look up a variable in an environment and, failing that, in each of the
+// scopes enclosing it. The link from a child scope to its parent is skipped,
+// so that a caller-supplied variable of the same name remains visible.
+func lookupVariable(env map[string]interface{}, name string) (interface{}, bool) {
+	for env != nil {
+		if value, ok := env[name]; ok {
+			if _, isLink := value.(parentScope); !isLink {
+				return value, true
+			}
+		}
+		parent, _ := env[parentScopeKey].(parentScope)
+		env = parent
+	}
+	return nil, false
+}
@@ -3,7 +3,13 @@ package authz
 import (
 	"errors"
 	"fmt"
+	"math"
+	"net/netip"
+	"reflect"
+	"regexp"
+	"strings"
 	"testing"
+	"time"
 )
 
 const (
@@ -341,6 +347,114 @@ func TestEvalEq(t *testing.T) {
 	}
 }
 
+// Evaluator can evaluate inequality expressions.
+func TestEvalNe(t *testing.T) {
+	data := []struct {
+		input       Expr
+		want        bool
+		expectError error
+	}{
+		{NeExpr{StrExpr{"foo"}, StrExpr{"foo"}}, false, nil},
+		{NeExpr{StrExpr{"foo"}, StrExpr{"bar"}}, true, nil},
+		{NeExpr{UintExpr{42}, UintExpr{42}}, false, nil},
+		{NeExpr{UintExpr{42}, UintExpr{43}}, true, nil},
+		{NeExpr{TrueExpr{}, TrueExpr{}}, false, nil},
+		{NeExpr{TrueExpr{}, FalseExpr{}}, true, nil},
+		{NeExpr{StrExpr{"foo"}, UintExpr{42}}, false, errors.New("")},
+		{NeExpr{UintExpr{42}, StrExpr{"foo"}}, false, errors.New("")},
+		{NeExpr{TrueExpr{}, StrExpr{"foo"}}, false, errors.New("")},
+		{NeExpr{StrSliceExpr{[]Expr{}}, StrSliceExpr{[]Expr{}}}, false, errors.New("")},
+	}
+	for _, d := range data {
+		t.Run(fmt.Sprintf("%v", d.input), func(t *testing.T) {
+			ev := Evaluator{}
+
+			got, err := ev.Eval(d.input, make(map[string]interface{}))
+			if err != nil {
+				if d.expectError == nil {
+					t.Fatalf("unexpected error: %v", err)
+				} else {
+					return
+				}
+			}
+
+			if d.expectError != nil {
+				if err == nil {
+					t.Fatalf("expected error: %v", d.expectError)
+				} else {
+					return
+				}
+			}
+
+			b, err := coerceBool(got)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if b != d.want {
+				t.Fatalf("got %v, want %v", got, d.want)
+			}
+		})
+	}
+}
+
+// Evaluator can evaluate ordering comparison expressions.
+func TestEvalOrdering(t *testing.T) {
+	data := []struct {
+		input       Expr
+		want        bool
+		expectError error
+	}{
+		{LtExpr{UintExpr{1}, UintExpr{2}}, true, nil},
+		{LtExpr{UintExpr{2}, UintExpr{2}}, false, nil},
+		{LteExpr{UintExpr{2}, UintExpr{2}}, true, nil},
+		{LteExpr{UintExpr{3}, UintExpr{2}}, false, nil},
+		{GtExpr{UintExpr{3}, UintExpr{2}}, true, nil},
+		{GtExpr{UintExpr{2}, UintExpr{2}}, false, nil},
+		{GteExpr{UintExpr{2}, UintExpr{2}}, true, nil},
+		{GteExpr{UintExpr{1}, UintExpr{2}}, false, nil},
+		{LtExpr{StrExpr{"abc"}, StrExpr{"abd"}}, true, nil},
+		{GtExpr{StrExpr{"b"}, StrExpr{"abc"}}, true, nil},
+		{LteExpr{StrExpr{"foo"}, StrExpr{"foo"}}, true, nil},
+		{GteExpr{StrExpr{""}, StrExpr{"a"}}, false, nil},
+		{LtExpr{StrExpr{"foo"}, UintExpr{42}}, false, errors.New("")},
+		{GtExpr{UintExpr{42}, StrExpr{"foo"}}, false, errors.New("")},
+		{LteExpr{TrueExpr{}, FalseExpr{}}, false, errors.New("")},
+		{GteExpr{UintExpr{1}, TrueExpr{}}, false, errors.New("")},
+	}
+	for _, d := range data {
+		t.Run(fmt.Sprintf("%v", d.input), func(t *testing.T) {
+			ev := Evaluator{}
+
+			got, err := ev.Eval(d.input, make(map[string]interface{}))
+			if err != nil {
+				if d.expectError == nil {
+					t.Fatalf("unexpected error: %v", err)
+				} else {
+					return
+				}
+			}
+
+			if d.expectError != nil {
+				if err == nil {
+					t.Fatalf("expected error: %v", d.expectError)
+				} else {
+					return
+				}
+			}
+
+			b, err := coerceBool(got)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if b != d.want {
+				t.Fatalf("got %v, want %v", got, d.want)
+			}
+		})
+	}
+}
+
 // Evaluator can evaliate $in expressions.
 func TestEvalIn(t *testing.T) {
 	data := []struct {
@@ -396,22 +510,25 @@ func TestEvalIn(t *testing.T) {
 	}
 }
 
-// Evaluator can evaluate AND expressions.
-func TestEvalAnd(t *testing.T) {
+// Evaluator can compare timestamps and durations.
+func TestEvalTimeComparison(t *testing.T) {
 	data := []struct {
 		input       Expr
 		want        bool
 		expectError error
 	}{
-		{AndExpr{[]Expr{TrueExpr{}, TrueExpr{}}}, true, nil},
-		{AndExpr{[]Expr{TrueExpr{}, FalseExpr{}}}, false, nil},
-		{AndExpr{[]Expr{FalseExpr{}, TrueExpr{}}}, false, nil},
-		{AndExpr{[]Expr{FalseExpr{}, FalseExpr{}}}, false, nil},
-		{AndExpr{[]Expr{StrExpr{"foo"}, StrExpr{"bar"}}}, true, nil},
-		{AndExpr{[]Expr{StrExpr{"foo"}, StrExpr{""}}}, false, nil},
-		{AndExpr{[]Expr{UintExpr{42}, UintExpr{43}}}, true, nil},
-		{AndExpr{[]Expr{UintExpr{42}, UintExpr{0}}}, false, nil},
-		{AndExpr{[]Expr{TrueExpr{}, StrExpr{"foo"}, UintExpr{1}}}, true, nil},
+		{EqExpr{TimeExpr{time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}, TimeExpr{time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).In(time.FixedZone("X", 3600))}}, true, nil},
+		{EqExpr{TimeExpr{time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}, TimeExpr{time.Date(2024, 1, 1, 0, 15, 0, 0, time.UTC)}}, false, nil},
+		{LtExpr{TimeExpr{time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}, TimeExpr{time.Date(2024, 1, 1, 0, 15, 0, 0, time.UTC)}}, true, nil},
+		{GteExpr{TimeExpr{time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}, TimeExpr{time.Date(2024, 1, 1, 0, 15, 0, 0, time.UTC)}}, false, nil},
+		{EqExpr{DurationExpr{time.Minute}, DurationExpr{60 * time.Second}}, true, nil},
+		{GtExpr{DurationExpr{time.Hour}, DurationExpr{time.Minute}}, true, nil},
+		{NeExpr{DurationExpr{time.Hour}, DurationExpr{time.Minute}}, true, nil},
+		{LtExpr{SubExpr{TimeExpr{time.Date(2024, 1, 1, 0, 15, 0, 0, time.UTC)}, TimeExpr{time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}}, DurationExpr{20 * time.Minute}}, true, nil},
+		{GteExpr{TimeExpr{time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}, SubExpr{NowExpr{func() time.Time { return time.Date(2024, 1, 1, 0, 15, 0, 0, time.UTC) }}, DurationExpr{15 * time.Minute}}}, true, nil},
+		{EqExpr{TimeExpr{time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}, DurationExpr{time.Minute}}, false, errors.New("")},
+		{LtExpr{DurationExpr{time.Minute}, UintExpr{60}}, false, errors.New("")},
+		{LtExpr{TimeExpr{time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}, StrExpr{"2024-01-01T00:00:00Z"}}, false, errors.New("")},
 	}
 	for _, d := range data {
 		t.Run(fmt.Sprintf("%v", d.input), func(t *testing.T) {
@@ -446,22 +563,82 @@ func TestEvalAnd(t *testing.T) {
 	}
 }
 
-// Evaluator can evaluate OR expressions.
-func TestEvalOr(t *testing.T) {
+type testSession struct {
+	When *time.Time
+	TTL  *time.Duration
+}
+
+// Evaluator dereferences pointers to timestamps and durations, e.g. optional struct fields.
+func TestEvalTimePointerFields(t *testing.T) {
+	when := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	ttl := time.Hour
+	now := NowExpr{func() time.Time { return time.Date(2024, 1, 1, 0, 15, 0, 0, time.UTC) }}
+	whenRef := StructFieldRefExpr{VarName: "session", Path: []PathSegment{{Name: "When"}}}
+	ttlRef := StructFieldRefExpr{VarName: "session", Path: []PathSegment{{Name: "TTL"}}}
+
+	data := []struct {
+		input       Expr
+		env         map[string]interface{}
+		want        interface{}
+		expectError error
+	}{
+		{LtExpr{whenRef, now}, map[string]interface{}{"session": testSession{When: &when}}, true, nil},
+		{EqExpr{whenRef, TimeExpr{when}}, map[string]interface{}{"session": testSession{When: &when}}, true, nil},
+		{GtExpr{AddExpr{whenRef, ttlRef}, now}, map[string]interface{}{"session": testSession{When: &when, TTL: &ttl}}, true, nil},
+		{GteExpr{ttlRef, DurationExpr{time.Minute}}, map[string]interface{}{"session": testSession{TTL: &ttl}}, true, nil},
+		{EqExpr{whenRef, NullExpr{}}, map[string]interface{}{"session": testSession{}}, true, nil},
+		{LtExpr{whenRef, now}, map[string]interface{}{"session": testSession{}}, nil, errors.New("")},
+	}
+	for _, d := range data {
+		t.Run(fmt.Sprintf("%v", d.input), func(t *testing.T) {
+			ev := Evaluator{}
+
+			got, err := ev.Eval(d.input, d.env)
+			if err != nil {
+				if d.expectError == nil {
+					t.Fatalf("unexpected error: %v", err)
+				} else {
+					return
+				}
+			}
+
+			if d.expectError != nil {
+				t.Fatalf("expected error: %v", d.expectError)
+			}
+
+			if got != d.want {
+				t.Fatalf("got %v (%T), want %v (%T)", got, got, d.want, d.want)
+			}
+		})
+	}
+}
+
+// Evaluator can compare numbers of different kinds.
+func TestEvalNumericComparison(t *testing.T) {
 	data := []struct {
 		input       Expr
 		want        bool
 		expectError error
 	}{
-		{OrExpr{[]Expr{TrueExpr{}, TrueExpr{}}}, true, nil},
-		{OrExpr{[]Expr{TrueExpr{}, FalseExpr{}}}, true, nil},
-		{OrExpr{[]Expr{FalseExpr{}, TrueExpr{}}}, true, nil},
-		{OrExpr{[]Expr{FalseExpr{}, FalseExpr{}}}, false, nil},
-		{OrExpr{[]Expr{StrExpr{"foo"}, StrExpr{"bar"}}}, true, nil},
-		{OrExpr{[]Expr{StrExpr{"foo"}, StrExpr{""}}}, true, nil},
-		{OrExpr{[]Expr{UintExpr{42}, UintExpr{43}}}, true, nil},
-		{OrExpr{[]Expr{UintExpr{42}, UintExpr{0}}}, true, nil},
-		{OrExpr{[]Expr{FalseExpr{}, StrExpr{""}, UintExpr{0}}}, false, nil},
+		{EqExpr{IntExpr{-1}, IntExpr{-1}}, true, nil},
+		{EqExpr{IntExpr{42}, UintExpr{42}}, true, nil},
+		{EqExpr{UintExpr{42}, FloatExpr{42}}, true, nil},
+		{EqExpr{FloatExpr{0.1}, FloatExpr{0.1}}, true, nil},
+		{EqExpr{IntExpr{-1}, UintExpr{math.MaxUint}}, false, nil},
+		{EqExpr{UintExpr{1<<53 + 1}, FloatExpr{1 << 53}}, false, nil},
+		{EqExpr{FloatExpr{math.NaN()}, FloatExpr{math.NaN()}}, false, nil},
+		{NeExpr{FloatExpr{1.5}, IntExpr{1}}, true, nil},
+		{LtExpr{IntExpr{-1}, UintExpr{0}}, true, nil},
+		{GtExpr{UintExpr{math.MaxUint}, IntExpr{math.MaxInt64}}, true, nil},
+		{LtExpr{FloatExpr{-0.5}, IntExpr{0}}, true, nil},
+		{GteExpr{FloatExpr{2.5}, UintExpr{3}}, false, nil},
+		{LteExpr{IntExpr{-3}, FloatExpr{-3}}, true, nil},
+		{LtExpr{FloatExpr{math.Inf(-1)}, IntExpr{math.MinInt64}}, true, nil},
+		{LtExpr{FloatExpr{math.NaN()}, IntExpr{0}}, false, errors.New("")},
+		{EqExpr{FloatExpr{1}, TrueExpr{}}, false, errors.New("")},
+		{LtExpr{IntExpr{1}, StrExpr{"2"}}, false, errors.New("")},
+		{AndExpr{[]Expr{IntExpr{-1}, FloatExpr{0.5}}}, true, nil},
+		{OrExpr{[]Expr{IntExpr{0}, FloatExpr{0}}}, false, nil},
 	}
 	for _, d := range data {
 		t.Run(fmt.Sprintf("%v", d.input), func(t *testing.T) {
@@ -496,26 +673,29 @@ func TestEvalOr(t *testing.T) {
 	}
 }
 
-// Evaluator can evaluate variable reference expressions.
-func TestEvalVariableRef(t *testing.T) {
+// Evaluator can evaluate string predicate expressions.
+func TestEvalStringPredicates(t *testing.T) {
 	data := []struct {
 		input       Expr
-		env         map[string]interface{}
-		want        interface{}
-		wantType    string
+		want        bool
 		expectError error
 	}{
-		{VariableRefExpr{"foo"}, map[string]interface{}{"foo": "bar"}, "bar", String, nil},
-		{VariableRefExpr{"foo"}, map[string]interface{}{"foo": 42}, 42, Uint, nil},
-		{VariableRefExpr{"foo"}, map[string]interface{}{"foo": true}, true, Bool, nil},
-		{VariableRefExpr{"foo"}, map[string]interface{}{}, nil, NA, errors.New("")},
-		{VariableRefExpr{"foo"}, map[string]interface{}{"bar": "baz"}, nil, NA, errors.New("")},
+		{StartsWithExpr{StrExpr{"org/42/project/7"}, StrExpr{"org/42/"}}, true, nil},
+		{StartsWithExpr{StrExpr{"org/43/project/7"}, StrExpr{"org/42/"}}, false, nil},
+		{StartsWithExpr{StrExpr{"foo"}, StrExpr{""}}, true, nil},
+		{EndsWithExpr{StrExpr{"alice@corp.com"}, StrExpr{"@corp.com"}}, true, nil},
+		{EndsWithExpr{StrExpr{"alice@evil.com"}, StrExpr{"@corp.com"}}, false, nil},
+		{ContainsExpr{StrExpr{"org/42/project/7"}, StrExpr{"/project/"}}, true, nil},
+		{ContainsExpr{StrExpr{"org/42"}, StrExpr{"project"}}, false, nil},
+		{StartsWithExpr{UintExpr{42}, StrExpr{"4"}}, false, errors.New("")},
+		{EndsWithExpr{StrExpr{"foo"}, TrueExpr{}}, false, errors.New("")},
+		{ContainsExpr{StrSliceExpr{[]Expr{StrExpr{"foo"}}}, StrExpr{"foo"}}, false, errors.New("")},
 	}
 	for _, d := range data {
 		t.Run(fmt.Sprintf("%v", d.input), func(t *testing.T) {
 			ev := Evaluator{}
 
-			got, err := ev.Eval(d.input, d.env)
+			got, err := ev.Eval(d.input, make(map[string]interface{}))
 			if err != nil {
 				if d.expectError == nil {
 					t.Fatalf("unexpected error: %v", err)
@@ -532,30 +712,35 @@ func TestEvalVariableRef(t *testing.T) {
 				}
 			}
 
-			if err := coerceAndCmp(d.want, got, d.wantType); err != nil {
+			b, err := coerceBool(got)
+			if err != nil {
 				t.Fatalf("unexpected error: %v", err)
 			}
+
+			if b != d.want {
+				t.Fatalf("got %v, want %v", got, d.want)
+			}
 		})
 	}
 }
 
-// Evaluator can evaluate struct field reference expressions.
-func TestEvalStructFieldRef(t *testing.T) {
+// Evaluator can evaluate $matches expressions.
+func TestEvalMatches(t *testing.T) {
 	data := []struct {
 		input       Expr
-		env         map[string]interface{}
-		want        interface{}
-		wantType    string
+		want        bool
 		expectError error
 	}{
-		{StructFieldRefExpr{"foo", "Bar"}, map[string]interface{}{"foo": struct{ Bar string }{"baz"}}, "baz", String, nil},
-		{StructFieldRefExpr{"foo", "Hello"}, map[string]interface{}{"foo": struct{ Bar string }{"baz"}}, "", NA, errors.New("")},
+		{MatchesExpr{StrExpr{"alice@corp.com"}, regexp.MustCompile(`@corp\.com$`)}, true, nil},
+		{MatchesExpr{StrExpr{"alice@corp.com.evil"}, regexp.MustCompile(`@corp\.com$`)}, false, nil},
+		{MatchesExpr{StrExpr{"tenant-7/doc"}, regexp.MustCompile(`^tenant-[0-9]+/`)}, true, nil},
+		{MatchesExpr{UintExpr{42}, regexp.MustCompile(`42`)}, false, errors.New("")},
 	}
 	for _, d := range data {
 		t.Run(fmt.Sprintf("%v", d.input), func(t *testing.T) {
 			ev := Evaluator{}
 
-			got, err := ev.Eval(d.input, d.env)
+			got, err := ev.Eval(d.input, make(map[string]interface{}))
 			if err != nil {
 				if d.expectError == nil {
 					t.Fatalf("unexpected error: %v", err)
@@ -572,67 +757,1148 @@ func TestEvalStructFieldRef(t *testing.T) {
 				}
 			}
 
-			if err := coerceAndCmp(d.want, got, d.wantType); err != nil {
+			b, err := coerceBool(got)
+			if err != nil {
 				t.Fatalf("unexpected error: %v", err)
 			}
+
+			if b != d.want {
+				t.Fatalf("got %v, want %v", got, d.want)
+			}
 		})
 	}
 }
 
-// Coerce the got value to the wantType and compare it with the want value.
-func coerceAndCmp(want, got interface{}, wantType string) error {
-	switch wantType {
-	case String:
-		w, err := coerceStr(want)
-		if err != nil {
-			return errors.New("specified the wrong wantType")
-		}
+// Evaluator can evaluate $glob expressions.
+func TestEvalGlob(t *testing.T) {
+	data := []struct {
+		input       Expr
+		want        bool
+		expectError error
+	}{
+		{GlobExpr{StrExpr{"documents/42/read"}, MustCompileGlob("documents/*/read")}, true, nil},
+		{GlobExpr{StrExpr{"documents/42/write"}, MustCompileGlob("documents/*/read")}, false, nil},
+		{GlobExpr{StrExpr{"bucket/a/b"}, MustCompileGlob("bucket/**")}, true, nil},
+		{GlobExpr{UintExpr{42}, MustCompileGlob("*")}, false, errors.New("")},
+		{InExpr{StrExpr{"bucket/a/b"}, GlobSliceExpr{[]Glob{MustCompileGlob("documents/*/read"), MustCompileGlob("bucket/**")}}}, true, nil},
+		{InExpr{StrExpr{"documents/1/write"}, GlobSliceExpr{[]Glob{MustCompileGlob("documents/*/read"), MustCompileGlob("bucket/**")}}}, false, nil},
+		{InExpr{UintExpr{42}, GlobSliceExpr{[]Glob{MustCompileGlob("*")}}}, false, errors.New("")},
+	}
+	for _, d := range data {
+		t.Run(fmt.Sprintf("%v", d.input), func(t *testing.T) {
+			ev := Evaluator{}
 
-		g, err := coerceStr(got)
-		if err != nil {
-			return fmt.Errorf("got the wrong type; expected string")
-		}
+			got, err := ev.Eval(d.input, make(map[string]interface{}))
+			if err != nil {
+				if d.expectError == nil {
+					t.Fatalf("unexpected error: %v", err)
+				} else {
+					return
+				}
+			}
 
-		if w != g {
-			return fmt.Errorf("got %v, want %v", got, want)
-		}
+			if d.expectError != nil {
+				if err == nil {
+					t.Fatalf("expected error: %v", d.expectError)
+				} else {
+					return
+				}
+			}
 
-		return nil
-	case Uint:
-		w, err := coerceUint(want)
-		if err != nil {
-			fmt.Println(err)
-			return errors.New("specified the wrong wantType")
-		}
+			b, err := coerceBool(got)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
 
-		g, err := coerceUint(got)
-		if err != nil {
-			fmt.Println(err)
-			return fmt.Errorf("got the wrong type; expected uint")
-		}
+			if b != d.want {
+				t.Fatalf("got %v, want %v", got, d.want)
+			}
+		})
+	}
+}
 
-		if w != g {
-			return fmt.Errorf("got %v, want %v", got, want)
-		}
+// Evaluator can evaluate IP network membership expressions.
+func TestEvalIPInCIDR(t *testing.T) {
+	data := []struct {
+		input       Expr
+		want        bool
+		expectError error
+	}{
+		{IPInCIDRExpr{StrExpr{"10.1.2.3"}, netip.MustParsePrefix("10.0.0.0/8")}, true, nil},
+		{IPInCIDRExpr{StrExpr{"11.1.2.3"}, netip.MustParsePrefix("10.0.0.0/8")}, false, nil},
+		{IPInCIDRExpr{StrExpr{"::ffff:10.1.2.3"}, netip.MustParsePrefix("10.0.0.0/8")}, true, nil},
+		{IPInCIDRExpr{StrExpr{"2001:db8::1"}, netip.MustParsePrefix("2001:db8::/32")}, true, nil},
+		{IPInCIDRExpr{StrExpr{"2001:db9::1"}, netip.MustParsePrefix("2001:db8::/32")}, false, nil},
+		{IPInCIDRExpr{StrExpr{"10.1.2.3"}, netip.MustParsePrefix("2001:db8::/32")}, false, nil},
+		{IPInCIDRExpr{StrExpr{"not-an-ip"}, netip.MustParsePrefix("10.0.0.0/8")}, false, errors.New("")},
+		{IPInCIDRExpr{UintExpr{42}, netip.MustParsePrefix("10.0.0.0/8")}, false, errors.New("")},
+		{InExpr{StrExpr{"192.168.1.1"}, CIDRSliceExpr{[]netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("192.168.0.0/16")}}}, true, nil},
+		{InExpr{StrExpr{"fd00::1"}, CIDRSliceExpr{[]netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("fd00::/8")}}}, true, nil},
+		{InExpr{StrExpr{"8.8.8.8"}, CIDRSliceExpr{[]netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}}}, false, nil},
+		{InExpr{TrueExpr{}, CIDRSliceExpr{[]netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}}}, false, errors.New("")},
+	}
+	for _, d := range data {
+		t.Run(fmt.Sprintf("%v", d.input), func(t *testing.T) {
+			ev := Evaluator{}
 
-		return nil
-	case Bool:
-		w, err := coerceBool(want)
-		if err != nil {
-			return errors.New("specified the wrong wantType")
-		}
+			got, err := ev.Eval(d.input, make(map[string]interface{}))
+			if err != nil {
+				if d.expectError == nil {
+					t.Fatalf("unexpected error: %v", err)
+				} else {
+					return
+				}
+			}
 
-		g, err := coerceBool(got)
-		if err != nil {
-			return fmt.Errorf("got the wrong type; expected bool")
+			if d.expectError != nil {
+				if err == nil {
+					t.Fatalf("expected error: %v", d.expectError)
+				} else {
+					return
+				}
+			}
+
+			b, err := coerceBool(got)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if b != d.want {
+				t.Fatalf("got %v, want %v", got, d.want)
+			}
+		})
+	}
+}
+
+// Evaluator can evaluate AND expressions.
+func TestEvalAnd(t *testing.T) {
+	data := []struct {
+		input       Expr
+		want        bool
+		expectError error
+	}{
+		{AndExpr{[]Expr{TrueExpr{}, TrueExpr{}}}, true, nil},
+		{AndExpr{[]Expr{TrueExpr{}, FalseExpr{}}}, false, nil},
+		{AndExpr{[]Expr{FalseExpr{}, TrueExpr{}}}, false, nil},
+		{AndExpr{[]Expr{FalseExpr{}, FalseExpr{}}}, false, nil},
+		{AndExpr{[]Expr{StrExpr{"foo"}, StrExpr{"bar"}}}, true, nil},
+		{AndExpr{[]Expr{StrExpr{"foo"}, StrExpr{""}}}, false, nil},
+		{AndExpr{[]Expr{UintExpr{42}, UintExpr{43}}}, true, nil},
+		{AndExpr{[]Expr{UintExpr{42}, UintExpr{0}}}, false, nil},
+		{AndExpr{[]Expr{TrueExpr{}, StrExpr{"foo"}, UintExpr{1}}}, true, nil},
+	}
+	for _, d := range data {
+		t.Run(fmt.Sprintf("%v", d.input), func(t *testing.T) {
+			ev := Evaluator{}
+
+			got, err := ev.Eval(d.input, make(map[string]interface{}))
+			if err != nil {
+				if d.expectError == nil {
+					t.Fatalf("unexpected error: %v", err)
+				} else {
+					return
+				}
+			}
+
+			if d.expectError != nil {
+				if err == nil {
+					t.Fatalf("expected error: %v", d.expectError)
+				} else {
+					return
+				}
+			}
+
+			b, err := coerceBool(got)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if b != d.want {
+				t.Fatalf("got %v, want %v", got, d.want)
+			}
+		})
+	}
+}
+
+// Evaluator can evaluate OR expressions.
+func TestEvalOr(t *testing.T) {
+	data := []struct {
+		input       Expr
+		want        bool
+		expectError error
+	}{
+		{OrExpr{[]Expr{TrueExpr{}, TrueExpr{}}}, true, nil},
+		{OrExpr{[]Expr{TrueExpr{}, FalseExpr{}}}, true, nil},
+		{OrExpr{[]Expr{FalseExpr{}, TrueExpr{}}}, true, nil},
+		{OrExpr{[]Expr{FalseExpr{}, FalseExpr{}}}, false, nil},
+		{OrExpr{[]Expr{StrExpr{"foo"}, StrExpr{"bar"}}}, true, nil},
+		{OrExpr{[]Expr{StrExpr{"foo"}, StrExpr{""}}}, true, nil},
+		{OrExpr{[]Expr{UintExpr{42}, UintExpr{43}}}, true, nil},
+		{OrExpr{[]Expr{UintExpr{42}, UintExpr{0}}}, true, nil},
+		{OrExpr{[]Expr{FalseExpr{}, StrExpr{""}, UintExpr{0}}}, false, nil},
+	}
+	for _, d := range data {
+		t.Run(fmt.Sprintf("%v", d.input), func(t *testing.T) {
+			ev := Evaluator{}
+
+			got, err := ev.Eval(d.input, make(map[string]interface{}))
+			if err != nil {
+				if d.expectError == nil {
+					t.Fatalf("unexpected error: %v", err)
+				} else {
+					return
+				}
+			}
+
+			if d.expectError != nil {
+				if err == nil {
+					t.Fatalf("expected error: %v", d.expectError)
+				} else {
+					return
+				}
+			}
+
+			b, err := coerceBool(got)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if b != d.want {
+				t.Fatalf("got %v, want %v", got, d.want)
+			}
+		})
+	}
+}
+
+// Evaluator can evaluate NOT expressions.
+func TestEvalNot(t *testing.T) {
+	data := []struct {
+		input       Expr
+		want        bool
+		expectError error
+	}{
+		{NotExpr{TrueExpr{}}, false, nil},
+		{NotExpr{FalseExpr{}}, true, nil},
+		{NotExpr{StrExpr{"foo"}}, false, nil},
+		{NotExpr{StrExpr{""}}, true, nil},
+		{NotExpr{UintExpr{42}}, false, nil},
+		{NotExpr{UintExpr{0}}, true, nil},
+		{NotExpr{NotExpr{TrueExpr{}}}, true, nil},
+		{NotExpr{EqExpr{StrExpr{"foo"}, UintExpr{42}}}, false, errors.New("")},
+		{NotExpr{StrSliceExpr{[]Expr{}}}, false, errors.New("")},
+	}
+	for _, d := range data {
+		t.Run(fmt.Sprintf("%v", d.input), func(t *testing.T) {
+			ev := Evaluator{}
+
+			got, err := ev.Eval(d.input, make(map[string]interface{}))
+			if err != nil {
+				if d.expectError == nil {
+					t.Fatalf("unexpected error: %v", err)
+				} else {
+					return
+				}
+			}
+
+			if d.expectError != nil {
+				if err == nil {
+					t.Fatalf("expected error: %v", d.expectError)
+				} else {
+					return
+				}
+			}
+
+			b, err := coerceBool(got)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if b != d.want {
+				t.Fatalf("got %v, want %v", got, d.want)
+			}
+		})
+	}
+}
+
+// Evaluator can evaluate variable reference expressions.
+func TestEvalVariableRef(t *testing.T) {
+	data := []struct {
+		input       Expr
+		env         map[string]interface{}
+		want        interface{}
+		wantType    string
+		expectError error
+	}{
+		{VariableRefExpr{"foo"}, map[string]interface{}{"foo": "bar"}, "bar", String, nil},
+		{VariableRefExpr{"foo"}, map[string]interface{}{"foo": 42}, 42, Uint, nil},
+		{VariableRefExpr{"foo"}, map[string]interface{}{"foo": true}, true, Bool, nil},
+		{VariableRefExpr{"foo"}, map[string]interface{}{}, nil, NA, errors.New("")},
+		{VariableRefExpr{"foo"}, map[string]interface{}{"bar": "baz"}, nil, NA, errors.New("")},
+	}
+	for _, d := range data {
+		t.Run(fmt.Sprintf("%v", d.input), func(t *testing.T) {
+			ev := Evaluator{}
+
+			got, err := ev.Eval(d.input, d.env)
+			if err != nil {
+				if d.expectError == nil {
+					t.Fatalf("unexpected error: %v", err)
+				} else {
+					return
+				}
+			}
+
+			if d.expectError != nil {
+				if err == nil {
+					t.Fatalf("expected error: %v", d.expectError)
+				} else {
+					return
+				}
+			}
+
+			if err := coerceAndCmp(d.want, got, d.wantType); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+type testOwner struct {
+	ID   uint
+	Name string
+}
+
+type testRegion struct {
+	Region string
+}
+
+type testOrg struct {
+	Owner *testOwner
+	Meta  interface{}
+}
+
+var jsonClaims = map[string]interface{}{
+	"sub":    "alice",
+	"groups": []interface{}{"admin", "dev"},
+	"org":    map[string]interface{}{"display name": "Acme"},
+}
+
+var nestedUser = struct{ Org testOrg }{testOrg{&testOwner{7, "alice"}, testRegion{"eu"}}}
+
+// Evaluator can evaluate struct field reference expressions.
+func TestEvalStructFieldRef(t *testing.T) {
+	data := []struct {
+		input       Expr
+		env         map[string]interface{}
+		want        interface{}
+		wantType    string
+		expectError error
+	}{
+		{StructFieldRefExpr{VarName: "foo", Path: []PathSegment{{Name: "Bar"}}}, map[string]interface{}{"foo": struct{ Bar string }{"baz"}}, "baz", String, nil},
+		{StructFieldRefExpr{VarName: "foo", Path: []PathSegment{{Name: "Hello"}}}, map[string]interface{}{"foo": struct{ Bar string }{"baz"}}, "", NA, errors.New("")},
+		{StructFieldRefExpr{VarName: "user", Path: []PathSegment{{Name: "Org"}, {Name: "Owner"}, {Name: "ID"}}}, map[string]interface{}{"user": nestedUser}, 7, Uint, nil},
+		{StructFieldRefExpr{VarName: "user", Path: []PathSegment{{Name: "Org"}, {Name: "Owner"}, {Name: "Name"}}}, map[string]interface{}{"user": &nestedUser}, "alice", String, nil},
+		{StructFieldRefExpr{VarName: "user", Path: []PathSegment{{Name: "Org"}, {Name: "Meta"}, {Name: "Region"}}}, map[string]interface{}{"user": nestedUser}, "eu", String, nil},
+		{StructFieldRefExpr{VarName: "user", Path: []PathSegment{{Name: "Org"}, {Name: "Owner"}, {Name: "Missing"}}}, map[string]interface{}{"user": nestedUser}, nil, NA, errors.New("")},
+		{StructFieldRefExpr{VarName: "user", Path: []PathSegment{{Name: "Org"}, {Name: "Owner"}, {Name: "ID"}, {Name: "X"}}}, map[string]interface{}{"user": nestedUser}, nil, NA, errors.New("")},
+		{StructFieldRefExpr{VarName: "user", Path: []PathSegment{{Name: "Org"}}}, map[string]interface{}{}, nil, NA, errors.New("")},
+		{StructFieldRefExpr{VarName: "claims", Path: []PathSegment{{Name: "sub"}}}, map[string]interface{}{"claims": jsonClaims}, "alice", String, nil},
+		{StructFieldRefExpr{VarName: "claims", Path: []PathSegment{{Name: "org"}, {Name: "display name"}}}, map[string]interface{}{"claims": jsonClaims}, "Acme", String, nil},
+		{StructFieldRefExpr{VarName: "claims", Path: []PathSegment{{Name: "groups"}, {Index: 1, IsIndex: true}}}, map[string]interface{}{"claims": jsonClaims}, "dev", String, nil},
+		{StructFieldRefExpr{VarName: "claims", Path: []PathSegment{{Name: "groups"}, {Index: 2, IsIndex: true}}}, map[string]interface{}{"claims": jsonClaims}, nil, NA, errors.New("")},
+		{StructFieldRefExpr{VarName: "claims", Path: []PathSegment{{Name: "missing"}}}, map[string]interface{}{"claims": jsonClaims}, nil, NA, errors.New("")},
+		{StructFieldRefExpr{VarName: "claims", Path: []PathSegment{{Index: 0, IsIndex: true}}}, map[string]interface{}{"claims": jsonClaims}, nil, NA, errors.New("")},
+		{StructFieldRefExpr{VarName: "ids", Path: []PathSegment{{Index: 1, IsIndex: true}}}, map[string]interface{}{"ids": [3]uint{4, 5, 6}}, 5, Uint, nil},
+	}
+	for _, d := range data {
+		t.Run(fmt.Sprintf("%v", d.input), func(t *testing.T) {
+			ev := Evaluator{}
+
+			got, err := ev.Eval(d.input, d.env)
+			if err != nil {
+				if d.expectError == nil {
+					t.Fatalf("unexpected error: %v", err)
+				} else {
+					return
+				}
+			}
+
+			if d.expectError != nil {
+				if err == nil {
+					t.Fatalf("expected error: %v", d.expectError)
+				} else {
+					return
+				}
+			}
+
+			if err := coerceAndCmp(d.want, got, d.wantType); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+// Coerce the got value to the wantType and compare it with the want value.
+func coerceAndCmp(want, got interface{}, wantType string) error {
+	switch wantType {
+	case String:
+		w, err := coerceStr(want)
+		if err != nil {
+			return errors.New("specified the wrong wantType")
+		}
+
+		g, err := coerceStr(got)
+		if err != nil {
+			return fmt.Errorf("got the wrong type; expected string")
+		}
+
+		if w != g {
+			return fmt.Errorf("got %v, want %v", got, want)
+		}
+
+		return nil
+	case Uint:
+		w, err := coerceUint(want)
+		if err != nil {
+			fmt.Println(err)
+			return errors.New("specified the wrong wantType")
+		}
+
+		g, err := coerceUint(got)
+		if err != nil {
+			fmt.Println(err)
+			return fmt.Errorf("got the wrong type; expected uint")
+		}
+
+		if w != g {
+			return fmt.Errorf("got %v, want %v", got, want)
+		}
+
+		return nil
+	case Bool:
+		w, err := coerceBool(want)
+		if err != nil {
+			return errors.New("specified the wrong wantType")
 		}
 
-		if w != g {
-			return fmt.Errorf("got %v, want %v", got, want)
-		}
+		g, err := coerceBool(got)
+		if err != nil {
+			return fmt.Errorf("got the wrong type; expected bool")
+		}
+
+		if w != g {
+			return fmt.Errorf("got %v, want %v", got, want)
+		}
+
+		return nil
+	default:
+		return fmt.Errorf("unexpected wantType %v", wantType)
+	}
+}
+
+// Evaluator reports the path segment that failed to resolve.
+func TestEvalStructFieldRefError(t *testing.T) {
+	ev := Evaluator{}
+	expr := StructFieldRefExpr{VarName: "user", Path: []PathSegment{{Name: "Org"}, {Name: "Owner"}, {Name: "Missing"}}}
+
+	_, err := ev.Eval(expr, map[string]interface{}{"user": nestedUser})
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+
+	if !strings.Contains(err.Error(), "Missing of user.Org.Owner") {
+		t.Fatalf("error does not name the failing segment: %v", err)
+	}
+}
+
+// Evaluator reports the index that failed to resolve, with key segments in
+// the resolved path.
+func TestEvalStructFieldRefIndexError(t *testing.T) {
+	ev := Evaluator{}
+	expr := StructFieldRefExpr{VarName: "claims", Path: []PathSegment{{Name: "groups"}, {Index: 5, IsIndex: true}}}
+
+	_, err := ev.Eval(expr, map[string]interface{}{"claims": jsonClaims})
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+
+	if !strings.Contains(err.Error(), "index 5 of claims.groups") {
+		t.Fatalf("error does not name the failing segment: %v", err)
+	}
+}
+
+type testEmployee struct {
+	ID      uint
+	Manager *testEmployee
+}
+
+// Evaluator resolves nil values in references to an error, or to nil when optional.
+func TestEvalOptionalChaining(t *testing.T) {
+	withManager := &testEmployee{ID: 1, Manager: &testEmployee{ID: 2}}
+	withoutManager := &testEmployee{ID: 1}
+	var nilEmployee *testEmployee
+
+	data := []struct {
+		input       Expr
+		env         map[string]interface{}
+		want        interface{}
+		expectError error
+	}{
+		{StructFieldRefExpr{VarName: "user", Path: []PathSegment{{Name: "Manager"}, {Name: "ID"}}}, map[string]interface{}{"user": withManager}, uint(2), nil},
+		{StructFieldRefExpr{VarName: "user", Path: []PathSegment{{Name: "Manager"}, {Name: "ID"}}}, map[string]interface{}{"user": withoutManager}, nil, ErrNilDereference},
+		{StructFieldRefExpr{VarName: "user", Path: []PathSegment{{Name: "ID"}}}, map[string]interface{}{"user": nilEmployee}, nil, ErrNilDereference},
+		{StructFieldRefExpr{VarName: "user", Path: []PathSegment{{Name: "ID"}}}, map[string]interface{}{"user": nil}, nil, ErrNilDereference},
+		{StructFieldRefExpr{VarName: "user", Path: []PathSegment{{Name: "Manager"}, {Name: "ID", Optional: true}}}, map[string]interface{}{"user": withManager}, uint(2), nil},
+		{StructFieldRefExpr{VarName: "user", Path: []PathSegment{{Name: "Manager"}, {Name: "ID", Optional: true}}}, map[string]interface{}{"user": withoutManager}, nil, nil},
+		{StructFieldRefExpr{VarName: "user", Path: []PathSegment{{Name: "Manager", Optional: true}, {Name: "ID"}}}, map[string]interface{}{"user": nilEmployee}, nil, nil},
+		{StructFieldRefExpr{VarName: "user", Path: []PathSegment{{Name: "Manager", Optional: true}, {Name: "Manager"}, {Name: "ID"}}}, map[string]interface{}{"user": withManager}, nil, ErrNilDereference},
+		{StructFieldRefExpr{VarName: "list", Path: []PathSegment{{Index: 0, IsIndex: true, Optional: true}}}, map[string]interface{}{"list": []string(nil)}, nil, ErrNotFound},
+		{StructFieldRefExpr{VarName: "m", Path: []PathSegment{{Name: "k", Optional: true}}}, map[string]interface{}{"m": map[string]string(nil)}, nil, ErrNotFound},
+	}
+	for _, d := range data {
+		t.Run(fmt.Sprintf("%v", d.input), func(t *testing.T) {
+			ev := Evaluator{}
+
+			got, err := ev.Eval(d.input, d.env)
+			if err != nil {
+				if d.expectError == nil {
+					t.Fatalf("unexpected error: %v", err)
+				} else if !errors.Is(err, d.expectError) {
+					t.Fatalf("got error %v, want %v", err, d.expectError)
+				} else {
+					return
+				}
+			}
+
+			if d.expectError != nil {
+				t.Fatalf("expected error: %v", d.expectError)
+			}
+
+			if got != d.want {
+				t.Fatalf("got %v, want %v", got, d.want)
+			}
+		})
+	}
+}
+
+// Evaluator can test for missing values and supply defaults.
+func TestEvalNullExistsCoalesce(t *testing.T) {
+	data := []struct {
+		input       Expr
+		env         map[string]interface{}
+		want        bool
+		expectError error
+	}{
+		{EqExpr{NullExpr{}, NullExpr{}}, nil, true, nil},
+		{EqExpr{VariableRefExpr{"manager"}, NullExpr{}}, map[string]interface{}{"manager": (*testEmployee)(nil)}, true, nil},
+		{EqExpr{StrExpr{"eng"}, NullExpr{}}, nil, false, nil},
+		{NeExpr{UintExpr{0}, NullExpr{}}, nil, true, nil},
+		{EqExpr{VariableRefExpr{"missing"}, NullExpr{}}, nil, false, errors.New("")},
+		{ExistsExpr{VariableRefExpr{"dept"}}, map[string]interface{}{"dept": "eng"}, true, nil},
+		{ExistsExpr{VariableRefExpr{"dept"}}, map[string]interface{}{"dept": ""}, true, nil},
+		{ExistsExpr{VariableRefExpr{"dept"}}, map[string]interface{}{"dept": nil}, false, nil},
+		{ExistsExpr{VariableRefExpr{"dept"}}, nil, false, nil},
+		{ExistsExpr{VariableRefExpr{"groups"}}, map[string]interface{}{"groups": []string(nil)}, true, nil},
+		{ExistsExpr{VariableRefExpr{"groups"}}, map[string]interface{}{"groups": []string{}}, true, nil},
+		{NotExpr{VariableRefExpr{"groups"}}, map[string]interface{}{"groups": []string(nil)}, false, errors.New("")},
+		{NotExpr{VariableRefExpr{"groups"}}, map[string]interface{}{"groups": []string{}}, false, errors.New("")},
+		{NotExpr{VariableRefExpr{"dept"}}, map[string]interface{}{"dept": (*string)(nil)}, true, nil},
+		{ExistsExpr{StructFieldRefExpr{VarName: "claims", Path: []PathSegment{{Name: "dept"}}}}, map[string]interface{}{"claims": map[string]interface{}{"dept": "eng"}}, true, nil},
+		{ExistsExpr{StructFieldRefExpr{VarName: "claims", Path: []PathSegment{{Name: "dept"}}}}, map[string]interface{}{"claims": map[string]interface{}{}}, false, nil},
+		{ExistsExpr{StructFieldRefExpr{VarName: "user", Path: []PathSegment{{Name: "Manager"}, {Name: "ID"}}}}, map[string]interface{}{"user": &testEmployee{ID: 1}}, false, nil},
+		{ExistsExpr{StructFieldRefExpr{VarName: "user", Path: []PathSegment{{Name: "Manager"}}}}, map[string]interface{}{"user": &testEmployee{ID: 1}}, false, nil},
+		{ExistsExpr{StructFieldRefExpr{VarName: "user", Path: []PathSegment{{Name: "Salary"}}}}, map[string]interface{}{"user": &testEmployee{ID: 1}}, false, nil},
+		{ExistsExpr{StructFieldRefExpr{VarName: "list", Path: []PathSegment{{Index: 2, IsIndex: true}}}}, map[string]interface{}{"list": []string{"a"}}, false, nil},
+		{ExistsExpr{StructFieldRefExpr{VarName: "list", Path: []PathSegment{{Index: 0, IsIndex: true}}}}, map[string]interface{}{"list": []string{"a"}}, true, nil},
+		{ExistsExpr{StructFieldRefExpr{VarName: "n", Path: []PathSegment{{Name: "x"}}}}, map[string]interface{}{"n": 1}, false, errors.New("")},
+		{EqExpr{CoalesceExpr{[]Expr{VariableRefExpr{"dept"}, StrExpr{"none"}}}, StrExpr{"none"}}, nil, true, nil},
+		{EqExpr{CoalesceExpr{[]Expr{VariableRefExpr{"dept"}, StrExpr{"none"}}}, StrExpr{"eng"}}, map[string]interface{}{"dept": "eng"}, true, nil},
+		{EqExpr{CoalesceExpr{[]Expr{VariableRefExpr{"dept"}, StrExpr{"none"}}}, StrExpr{"none"}}, map[string]interface{}{"dept": nil}, true, nil},
+		{EqExpr{CoalesceExpr{[]Expr{StructFieldRefExpr{VarName: "user", Path: []PathSegment{{Name: "Manager"}, {Name: "ID"}}}, UintExpr{0}}}, UintExpr{0}}, map[string]interface{}{"user": &testEmployee{ID: 1}}, true, nil},
+		{EqExpr{CoalesceExpr{[]Expr{VariableRefExpr{"a"}, VariableRefExpr{"b"}}}, NullExpr{}}, nil, true, nil},
+		{EqExpr{CoalesceExpr{[]Expr{EqExpr{StrExpr{"a"}, TrueExpr{}}, StrExpr{"none"}}}, StrExpr{"none"}}, nil, false, errors.New("")},
+	}
+	for _, d := range data {
+		t.Run(fmt.Sprintf("%v", d.input), func(t *testing.T) {
+			ev := Evaluator{}
+
+			got, err := ev.Eval(d.input, d.env)
+			if err != nil {
+				if d.expectError == nil {
+					t.Fatalf("unexpected error: %v", err)
+				} else {
+					return
+				}
+			}
+
+			if d.expectError != nil {
+				if err == nil {
+					t.Fatalf("expected error: %v", d.expectError)
+				} else {
+					return
+				}
+			}
+
+			b, err := coerceBool(got)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if b != d.want {
+				t.Fatalf("got %v, want %v", got, d.want)
+			}
+		})
+	}
+}
+
+// Evaluator can test membership of any collection.
+func TestEvalInCollections(t *testing.T) {
+	data := []struct {
+		input       Expr
+		env         map[string]interface{}
+		want        bool
+		expectError error
+	}{
+		{InExpr{StrExpr{"admin"}, VariableRefExpr{"c"}}, map[string]interface{}{"c": []interface{}{"viewer", "admin"}}, true, nil},
+		{InExpr{UintExpr{2}, VariableRefExpr{"c"}}, map[string]interface{}{"c": []interface{}{float64(1), float64(2)}}, true, nil},
+		{InExpr{UintExpr{3}, VariableRefExpr{"c"}}, map[string]interface{}{"c": []interface{}{float64(1), float64(2)}}, false, nil},
+		{InExpr{StrExpr{"admin"}, VariableRefExpr{"c"}}, map[string]interface{}{"c": []interface{}{"admin", float64(1)}}, false, errors.New("")},
+		{InExpr{StrExpr{"admin"}, VariableRefExpr{"c"}}, map[string]interface{}{"c": []interface{}{}}, false, nil},
+		{InExpr{StrExpr{"b"}, VariableRefExpr{"c"}}, map[string]interface{}{"c": [3]string{"a", "b", "c"}}, true, nil},
+		{InExpr{IntExpr{-1}, VariableRefExpr{"c"}}, map[string]interface{}{"c": [2]int{-1, 1}}, true, nil},
+		{InExpr{UintExpr{1}, VariableRefExpr{"c"}}, map[string]interface{}{"c": []int{-1, 1}}, true, nil},
+		{InExpr{StrExpr{"admin"}, VariableRefExpr{"c"}}, map[string]interface{}{"c": []testRole{"viewer", "admin"}}, true, nil},
+		{InExpr{TrueExpr{}, VariableRefExpr{"c"}}, map[string]interface{}{"c": []testFlag{false}}, false, nil},
+		{InExpr{TrueExpr{}, VariableRefExpr{"c"}}, map[string]interface{}{"c": []string{"true"}}, false, errors.New("")},
+		{InExpr{StrExpr{"read"}, VariableRefExpr{"c"}}, map[string]interface{}{"c": map[string]struct{}{"read": {}, "write": {}}}, true, nil},
+		{InExpr{StrExpr{"delete"}, VariableRefExpr{"c"}}, map[string]interface{}{"c": map[string]struct{}{"read": {}, "write": {}}}, false, nil},
+		{InExpr{UintExpr{7}, VariableRefExpr{"c"}}, map[string]interface{}{"c": map[uint]bool{7: false}}, true, nil},
+		{InExpr{UintExpr{7}, VariableRefExpr{"c"}}, map[string]interface{}{"c": map[string]bool{"7": true}}, false, errors.New("")},
+		{InExpr{VariableRefExpr{"r"}, VariableRefExpr{"c"}}, map[string]interface{}{"r": testRole("admin"), "c": map[string]bool{"admin": true}}, true, nil},
+		{InExpr{StrExpr{"admin"}, VariableRefExpr{"c"}}, map[string]interface{}{"c": map[testRole]bool{"admin": true}}, true, nil},
+		{InExpr{IntExpr{-1}, VariableRefExpr{"c"}}, map[string]interface{}{"c": map[uint]bool{1: true}}, false, nil},
+		{InExpr{UintExpr{300}, VariableRefExpr{"c"}}, map[string]interface{}{"c": map[int8]bool{44: true}}, false, nil},
+		{InExpr{FloatExpr{2}, VariableRefExpr{"c"}}, map[string]interface{}{"c": map[int]bool{2: true}}, true, nil},
+		{InExpr{TrueExpr{}, VariableRefExpr{"c"}}, map[string]interface{}{"c": map[bool]string{true: "yes"}}, true, nil},
+		{InExpr{StrExpr{"admin"}, VariableRefExpr{"c"}}, map[string]interface{}{"c": map[interface{}]bool{"admin": true, 1: true}}, false, errors.New("")},
+		{InExpr{StrExpr{"admin"}, VariableRefExpr{"c"}}, map[string]interface{}{"c": map[string]bool(nil)}, false, nil},
+		{InExpr{StrExpr{"a"}, VariableRefExpr{"c"}}, map[string]interface{}{"c": []string(nil)}, false, nil},
+		{InExpr{StrExpr{"a"}, VariableRefExpr{"c"}}, map[string]interface{}{"c": "abc"}, false, errors.New("")},
+		{InExpr{NullExpr{}, VariableRefExpr{"c"}}, map[string]interface{}{"c": []interface{}{"a", nil}}, true, nil},
+		{InExpr{FloatExpr{2}, UintSliceExpr{[]Expr{UintExpr{1}, UintExpr{2}}}}, nil, true, nil},
+	}
+	for _, d := range data {
+		t.Run(fmt.Sprintf("%v", d.input), func(t *testing.T) {
+			ev := Evaluator{}
+
+			got, err := ev.Eval(d.input, d.env)
+			if err != nil {
+				if d.expectError == nil {
+					t.Fatalf("unexpected error: %v", err)
+				} else {
+					return
+				}
+			}
+
+			if d.expectError != nil {
+				if err == nil {
+					t.Fatalf("expected error: %v", d.expectError)
+				} else {
+					return
+				}
+			}
+
+			b, err := coerceBool(got)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if b != d.want {
+				t.Fatalf("got %v, want %v", got, d.want)
+			}
+		})
+	}
+}
+
+// Evaluator can evaluate slice literals with non-literal elements.
+func TestEvalSliceElements(t *testing.T) {
+	data := []struct {
+		input       Expr
+		env         map[string]interface{}
+		want        bool
+		expectError error
+	}{
+		{InExpr{VariableRefExpr{"owner"}, StrSliceExpr{[]Expr{VariableRefExpr{"creator"}, StrExpr{"admin"}}}}, map[string]interface{}{"owner": "bob", "creator": "bob"}, true, nil},
+		{InExpr{VariableRefExpr{"owner"}, StrSliceExpr{[]Expr{VariableRefExpr{"creator"}, StrExpr{"admin"}}}}, map[string]interface{}{"owner": "eve", "creator": "bob"}, false, nil},
+		{InExpr{StrExpr{"bob"}, StrSliceExpr{[]Expr{VariableRefExpr{"creator"}}}}, map[string]interface{}{"creator": testRole("bob")}, true, nil},
+		{InExpr{StrExpr{"bob"}, StrSliceExpr{[]Expr{VariableRefExpr{"creator"}}}}, map[string]interface{}{"creator": 1}, false, errors.New("")},
+		{InExpr{StrExpr{"bob"}, StrSliceExpr{[]Expr{VariableRefExpr{"creator"}}}}, nil, false, errors.New("")},
+		{InExpr{UintExpr{5}, UintSliceExpr{[]Expr{AddExpr{VariableRefExpr{"a"}, VariableRefExpr{"b"}}}}}, map[string]interface{}{"a": time.Second, "b": time.Second}, false, errors.New("")},
+		{InExpr{UintExpr{5}, UintSliceExpr{[]Expr{VariableRefExpr{"quota"}, UintExpr{1}}}}, map[string]interface{}{"quota": uint16(5)}, true, nil},
+		{InExpr{UintExpr{5}, UintSliceExpr{[]Expr{VariableRefExpr{"quota"}}}}, map[string]interface{}{"quota": -5}, false, errors.New("")},
+		{InExpr{FalseExpr{}, BoolSliceExpr{[]Expr{EqExpr{VariableRefExpr{"a"}, StrExpr{"x"}}}}}, map[string]interface{}{"a": "y"}, true, nil},
+	}
+	for _, d := range data {
+		t.Run(fmt.Sprintf("%v", d.input), func(t *testing.T) {
+			ev := Evaluator{}
+
+			got, err := ev.Eval(d.input, d.env)
+			if err != nil {
+				if d.expectError == nil {
+					t.Fatalf("unexpected error: %v", err)
+				} else {
+					return
+				}
+			}
+
+			if d.expectError != nil {
+				if err == nil {
+					t.Fatalf("expected error: %v", d.expectError)
+				} else {
+					return
+				}
+			}
+
+			b, err := coerceBool(got)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if b != d.want {
+				t.Fatalf("got %v, want %v", got, d.want)
+			}
+		})
+	}
+}
+
+// Evaluator can evaluate set relation expressions.
+func TestEvalSetRelations(t *testing.T) {
+	data := []struct {
+		input       Expr
+		env         map[string]interface{}
+		want        bool
+		expectError error
+	}{
+		{SubsetExpr{StrSliceExpr{[]Expr{StrExpr{"read"}}}, VariableRefExpr{"scopes"}}, map[string]interface{}{"scopes": []string{"read", "write"}}, true, nil},
+		{SubsetExpr{StrSliceExpr{[]Expr{StrExpr{"read"}, StrExpr{"admin"}}}, VariableRefExpr{"scopes"}}, map[string]interface{}{"scopes": []string{"read", "write"}}, false, nil},
+		{SubsetExpr{StrSliceExpr{[]Expr{}}, VariableRefExpr{"scopes"}}, map[string]interface{}{"scopes": []string{}}, true, nil},
+		{IntersectsExpr{VariableRefExpr{"roles"}, StrSliceExpr{[]Expr{StrExpr{"admin"}, StrExpr{"owner"}}}}, map[string]interface{}{"roles": testRoles{"viewer", "owner"}}, true, nil},
+		{IntersectsExpr{VariableRefExpr{"roles"}, StrSliceExpr{[]Expr{StrExpr{"admin"}, StrExpr{"owner"}}}}, map[string]interface{}{"roles": []interface{}{"viewer"}}, false, nil},
+		{IntersectsExpr{VariableRefExpr{"roles"}, VariableRefExpr{"groups"}}, map[string]interface{}{"roles": map[string]struct{}{"a": {}}, "groups": [2]string{"b", "a"}}, true, nil},
+		{DisjointExpr{VariableRefExpr{"roles"}, StrSliceExpr{[]Expr{StrExpr{"banned"}}}}, map[string]interface{}{"roles": []string{"viewer"}}, true, nil},
+		{DisjointExpr{VariableRefExpr{"roles"}, StrSliceExpr{[]Expr{StrExpr{"banned"}}}}, map[string]interface{}{"roles": []string{"banned"}}, false, nil},
+		{EqualSetsExpr{VariableRefExpr{"ids"}, UintSliceExpr{[]Expr{UintExpr{2}, UintExpr{1}}}}, map[string]interface{}{"ids": []int{1, 2, 2}}, true, nil},
+		{EqualSetsExpr{VariableRefExpr{"ids"}, UintSliceExpr{[]Expr{UintExpr{2}, UintExpr{1}}}}, map[string]interface{}{"ids": []int{1}}, false, nil},
+		{SubsetExpr{VariableRefExpr{"ids"}, StrSliceExpr{[]Expr{StrExpr{"1"}}}}, map[string]interface{}{"ids": []int{1}}, false, errors.New("")},
+		{IntersectsExpr{StrExpr{"admin"}, StrSliceExpr{[]Expr{StrExpr{"admin"}}}}, nil, false, errors.New("")},
+		{DisjointExpr{VariableRefExpr{"missing"}, StrSliceExpr{[]Expr{}}}, nil, false, errors.New("")},
+	}
+	for _, d := range data {
+		t.Run(fmt.Sprintf("%v", d.input), func(t *testing.T) {
+			ev := Evaluator{}
 
-		return nil
-	default:
-		return fmt.Errorf("unexpected wantType %v", wantType)
+			got, err := ev.Eval(d.input, d.env)
+			if err != nil {
+				if d.expectError == nil {
+					t.Fatalf("unexpected error: %v", err)
+				} else {
+					return
+				}
+			}
+
+			if d.expectError != nil {
+				if err == nil {
+					t.Fatalf("expected error: %v", d.expectError)
+				} else {
+					return
+				}
+			}
+
+			b, err := coerceBool(got)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if b != d.want {
+				t.Fatalf("got %v, want %v", got, d.want)
+			}
+		})
+	}
+}
+
+type testMembership struct {
+	Org  string
+	Role string
+}
+
+var memberUser = struct{ Memberships []testMembership }{[]testMembership{{"acme", "admin"}, {"initech", "viewer"}}}
+
+// Evaluator can evaluate quantifier expressions.
+func TestEvalQuantifiers(t *testing.T) {
+	data := []struct {
+		input       Expr
+		env         map[string]interface{}
+		want        bool
+		expectError error
+	}{
+		{AnyExpr{StructFieldRefExpr{VarName: "user", Path: []PathSegment{{Name: "Memberships"}}}, "m", AndExpr{[]Expr{EqExpr{StructFieldRefExpr{VarName: "m", Path: []PathSegment{{Name: "Org"}}}, StructFieldRefExpr{VarName: "resource", Path: []PathSegment{{Name: "Org"}}}}, EqExpr{StructFieldRefExpr{VarName: "m", Path: []PathSegment{{Name: "Role"}}}, StrExpr{"admin"}}}}}, map[string]interface{}{"user": memberUser, "resource": map[string]string{"Org": "acme"}}, true, nil},
+		{AnyExpr{StructFieldRefExpr{VarName: "user", Path: []PathSegment{{Name: "Memberships"}}}, "m", AndExpr{[]Expr{EqExpr{StructFieldRefExpr{VarName: "m", Path: []PathSegment{{Name: "Org"}}}, StructFieldRefExpr{VarName: "resource", Path: []PathSegment{{Name: "Org"}}}}, EqExpr{StructFieldRefExpr{VarName: "m", Path: []PathSegment{{Name: "Role"}}}, StrExpr{"admin"}}}}}, map[string]interface{}{"user": memberUser, "resource": map[string]string{"Org": "initech"}}, false, nil},
+		{AllExpr{StructFieldRefExpr{VarName: "user", Path: []PathSegment{{Name: "Memberships"}}}, "m", EqExpr{StructFieldRefExpr{VarName: "m", Path: []PathSegment{{Name: "Org"}}}, StrExpr{"acme"}}}, map[string]interface{}{"user": memberUser}, false, nil},
+		{AllExpr{VariableRefExpr{"ids"}, "id", LtExpr{VariableRefExpr{"id"}, UintExpr{10}}}, map[string]interface{}{"ids": []int{1, 2, 3}}, true, nil},
+		{AllExpr{VariableRefExpr{"ids"}, "id", LtExpr{VariableRefExpr{"id"}, UintExpr{3}}}, map[string]interface{}{"ids": []int{1, 2, 3}}, false, nil},
+		{AnyExpr{VariableRefExpr{"ids"}, "id", FalseExpr{}}, map[string]interface{}{"ids": []int{}}, false, nil},
+		{AllExpr{VariableRefExpr{"ids"}, "id", FalseExpr{}}, map[string]interface{}{"ids": []int{}}, true, nil},
+		{AnyExpr{VariableRefExpr{"set"}, "k", EqExpr{VariableRefExpr{"k"}, StrExpr{"b"}}}, map[string]interface{}{"set": map[string]struct{}{"a": {}, "b": {}}}, true, nil},
+		{AnyExpr{VariableRefExpr{"ids"}, "x", EqExpr{VariableRefExpr{"x"}, UintExpr{2}}}, map[string]interface{}{"ids": []int{1, 2}, "x": 2}, true, nil},
+		{AnyExpr{VariableRefExpr{"ids"}, "x", EqExpr{VariableRefExpr{"x"}, UintExpr{2}}}, map[string]interface{}{"ids": []int{1}, "x": 2}, false, nil},
+		{AnyExpr{VariableRefExpr{"a"}, "x", AnyExpr{VariableRefExpr{"b"}, "y", EqExpr{VariableRefExpr{"x"}, VariableRefExpr{"y"}}}}, map[string]interface{}{"a": []string{"p", "q"}, "b": []string{"q"}}, true, nil},
+		{AnyExpr{VariableRefExpr{"ids"}, "id", VariableRefExpr{"id"}}, map[string]interface{}{"ids": []interface{}{"a", nil}}, true, nil},
+		{AllExpr{VariableRefExpr{"ids"}, "id", VariableRefExpr{"id"}}, map[string]interface{}{"ids": []interface{}{"a", nil}}, false, nil},
+		{AnyExpr{VariableRefExpr{"ids"}, "id", TrueExpr{}}, map[string]interface{}{"ids": "abc"}, false, errors.New("")},
+		{AnyExpr{VariableRefExpr{"ids"}, "id", TrueExpr{}}, nil, false, errors.New("")},
+	}
+	for _, d := range data {
+		t.Run(fmt.Sprintf("%v", d.input), func(t *testing.T) {
+			ev := Evaluator{}
+
+			got, err := ev.Eval(d.input, d.env)
+			if err != nil {
+				if d.expectError == nil {
+					t.Fatalf("unexpected error: %v", err)
+				} else {
+					return
+				}
+			}
+
+			if d.expectError != nil {
+				if err == nil {
+					t.Fatalf("expected error: %v", d.expectError)
+				} else {
+					return
+				}
+			}
+
+			b, err := coerceBool(got)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if b != d.want {
+				t.Fatalf("got %v, want %v", got, d.want)
+			}
+		})
+	}
+}
+
+// Evaluator does not leak variables bound by a quantifier into the environment.
+func TestEvalQuantifierScope(t *testing.T) {
+	env := map[string]interface{}{"ids": []int{1, 2}, "id": "outer"}
+	expr := AllExpr{VariableRefExpr{"ids"}, "id", LtExpr{VariableRefExpr{"id"}, UintExpr{10}}}
+
+	ev := Evaluator{}
+	if _, err := ev.Eval(expr, env); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if env["id"] != "outer" {
+		t.Fatalf("got %v, want %v", env["id"], "outer")
+	}
+}
+
+// Evaluator can evaluate length and aggregate expressions.
+func TestEvalAggregates(t *testing.T) {
+	data := []struct {
+		input       Expr
+		env         map[string]interface{}
+		want        interface{}
+		expectError error
+	}{
+		{LenExpr{StrExpr{"héllo"}}, nil, uint(5), nil},
+		{LenExpr{VariableRefExpr{"role"}}, map[string]interface{}{"role": testRole("admin")}, uint(5), nil},
+		{LenExpr{VariableRefExpr{"approvals"}}, map[string]interface{}{"approvals": []interface{}{"a", 1}}, uint(2), nil},
+		{LenExpr{VariableRefExpr{"set"}}, map[string]interface{}{"set": map[string]struct{}{"a": {}}}, uint(1), nil},
+		{LenExpr{StrSliceExpr{[]Expr{}}}, nil, uint(0), nil},
+		{LenExpr{UintExpr{5}}, nil, nil, errors.New("")},
+		{CountExpr{VariableRefExpr{"ids"}, "id", GtExpr{VariableRefExpr{"id"}, UintExpr{1}}}, map[string]interface{}{"ids": []int{1, 2, 3}}, uint(2), nil},
+		{CountExpr{VariableRefExpr{"ids"}, "id", TrueExpr{}}, map[string]interface{}{"ids": []int{}}, uint(0), nil},
+		{CountExpr{VariableRefExpr{"ids"}, "id", VariableRefExpr{"id"}}, map[string]interface{}{"ids": []interface{}{nil}}, uint(0), nil},
+		{SumExpr{VariableRefExpr{"v"}}, map[string]interface{}{"v": []uint{1, 2, 3}}, uint(6), nil},
+		{SumExpr{VariableRefExpr{"v"}}, map[string]interface{}{"v": []int{5, -7}}, int64(-2), nil},
+		{SumExpr{VariableRefExpr{"v"}}, map[string]interface{}{"v": []interface{}{float64(1.5), float64(2)}}, 3.5, nil},
+		{SumExpr{VariableRefExpr{"v"}}, map[string]interface{}{"v": []int{}}, uint(0), nil},
+		{SumExpr{VariableRefExpr{"v"}}, map[string]interface{}{"v": []uint64{math.MaxUint64, 1}}, nil, errors.New("")},
+		{SumExpr{VariableRefExpr{"v"}}, map[string]interface{}{"v": []int64{math.MinInt64, -1}}, nil, errors.New("")},
+		{SumExpr{VariableRefExpr{"v"}}, map[string]interface{}{"v": []string{"1"}}, nil, errors.New("")},
+		{MinExpr{VariableRefExpr{"v"}}, map[string]interface{}{"v": []int{3, -1, 2}}, -1, nil},
+		{MaxExpr{VariableRefExpr{"v"}}, map[string]interface{}{"v": []interface{}{uint(3), -1, 2.5}}, uint(3), nil},
+		{MinExpr{VariableRefExpr{"v"}}, map[string]interface{}{"v": []string{"b", "a", "c"}}, "a", nil},
+		{MaxExpr{VariableRefExpr{"v"}}, map[string]interface{}{"v": []time.Duration{time.Second, time.Hour}}, time.Hour, nil},
+		{MinExpr{VariableRefExpr{"v"}}, map[string]interface{}{"v": []int{}}, nil, errors.New("")},
+		{MaxExpr{VariableRefExpr{"v"}}, map[string]interface{}{"v": []interface{}{1, "a"}}, nil, errors.New("")},
+		{MaxExpr{VariableRefExpr{"v"}}, map[string]interface{}{"v": []bool{true}}, nil, errors.New("")},
+	}
+	for _, d := range data {
+		t.Run(fmt.Sprintf("%v", d.input), func(t *testing.T) {
+			ev := Evaluator{}
+
+			got, err := ev.Eval(d.input, d.env)
+			if err != nil {
+				if d.expectError == nil {
+					t.Fatalf("unexpected error: %v", err)
+				} else {
+					return
+				}
+			}
+
+			if d.expectError != nil {
+				t.Fatalf("expected error: %v", d.expectError)
+			}
+
+			if got != d.want {
+				t.Fatalf("got %v (%T), want %v (%T)", got, got, d.want, d.want)
+			}
+		})
+	}
+}
+
+// Evaluator can evaluate numeric arithmetic expressions.
+func TestEvalNumericArithmetic(t *testing.T) {
+	data := []struct {
+		input       Expr
+		env         map[string]interface{}
+		want        interface{}
+		expectError error
+	}{
+		{AddExpr{VariableRefExpr{"used"}, VariableRefExpr{"requested"}}, map[string]interface{}{"used": uint32(90), "requested": 15}, int64(105), nil},
+		{AddExpr{UintExpr{1}, UintExpr{2}}, nil, uint(3), nil},
+		{AddExpr{UintExpr{math.MaxUint64}, UintExpr{1}}, nil, nil, errors.New("")},
+		{AddExpr{UintExpr{1}, StrExpr{"2"}}, nil, nil, errors.New("")},
+		{AddExpr{UintExpr{1}, DurationExpr{time.Second}}, nil, nil, errors.New("")},
+		{SubExpr{VariableRefExpr{"limit"}, VariableRefExpr{"used"}}, map[string]interface{}{"limit": uint(10), "used": uint(12)}, int64(-2), nil},
+		{SubExpr{IntExpr{math.MinInt64}, UintExpr{1}}, nil, nil, errors.New("")},
+		{MulExpr{VariableRefExpr{"price"}, FloatExpr{1.5}}, map[string]interface{}{"price": 10}, 15.0, nil},
+		{MulExpr{UintExpr{1 << 40}, UintExpr{1 << 40}}, nil, nil, errors.New("")},
+		{MulExpr{DurationExpr{time.Second}, UintExpr{2}}, nil, nil, errors.New("")},
+		{DivExpr{UintExpr{7}, UintExpr{2}}, nil, uint(3), nil},
+		{DivExpr{IntExpr{-7}, FloatExpr{2}}, nil, -3.5, nil},
+		{DivExpr{UintExpr{7}, VariableRefExpr{"n"}}, map[string]interface{}{"n": 0}, nil, errors.New("")},
+		{ModExpr{UintExpr{7}, UintExpr{4}}, nil, uint(3), nil},
+		{ModExpr{UintExpr{7}, UintExpr{0}}, nil, nil, errors.New("")},
+		{ModExpr{StrExpr{"7"}, UintExpr{4}}, nil, nil, errors.New("")},
+	}
+	for _, d := range data {
+		t.Run(fmt.Sprintf("%v", d.input), func(t *testing.T) {
+			ev := Evaluator{}
+
+			got, err := ev.Eval(d.input, d.env)
+			if err != nil {
+				if d.expectError == nil {
+					t.Fatalf("unexpected error: %v", err)
+				} else {
+					return
+				}
+			}
+
+			if d.expectError != nil {
+				t.Fatalf("expected error: %v", d.expectError)
+			}
+
+			if got != d.want {
+				t.Fatalf("got %v (%T), want %v (%T)", got, got, d.want, d.want)
+			}
+		})
+	}
+}
+
+// Evaluator can evaluate conditional expressions, evaluating only the chosen branch.
+func TestEvalConditionals(t *testing.T) {
+	data := []struct {
+		input       Expr
+		env         map[string]interface{}
+		want        interface{}
+		expectError error
+	}{
+		{IfExpr{VariableRefExpr{"public"}, StrExpr{"yes"}, VariableRefExpr{"missing"}}, map[string]interface{}{"public": true}, "yes", nil},
+		{IfExpr{VariableRefExpr{"public"}, VariableRefExpr{"missing"}, StrExpr{"no"}}, map[string]interface{}{"public": false}, "no", nil},
+		{IfExpr{VariableRefExpr{"public"}, VariableRefExpr{"missing"}, StrExpr{"no"}}, map[string]interface{}{"public": true}, nil, errors.New("")},
+		{IfExpr{StrExpr{""}, UintExpr{1}, UintExpr{2}}, nil, uint(2), nil},
+		{IfExpr{NullExpr{}, UintExpr{1}, UintExpr{2}}, nil, uint(2), nil},
+		{IfExpr{VariableRefExpr{"missing"}, UintExpr{1}, UintExpr{2}}, nil, nil, errors.New("")},
+		{SwitchExpr{VariableRefExpr{"kind"}, []SwitchCase{{StrExpr{"doc"}, UintExpr{1}}, {StrExpr{"folder"}, UintExpr{2}}}, UintExpr{3}}, map[string]interface{}{"kind": "folder"}, uint(2), nil},
+		{SwitchExpr{VariableRefExpr{"kind"}, []SwitchCase{{StrExpr{"doc"}, UintExpr{1}}, {StrExpr{"folder"}, UintExpr{2}}}, UintExpr{3}}, map[string]interface{}{"kind": "image"}, uint(3), nil},
+		{SwitchExpr{VariableRefExpr{"kind"}, []SwitchCase{{StrExpr{"doc"}, UintExpr{1}}}, nil}, map[string]interface{}{"kind": "image"}, nil, errors.New("")},
+		{SwitchExpr{VariableRefExpr{"kind"}, []SwitchCase{{StrExpr{"doc"}, UintExpr{1}}, {StrExpr{"doc"}, UintExpr{2}}}, nil}, map[string]interface{}{"kind": testRole("doc")}, uint(1), nil},
+		{SwitchExpr{VariableRefExpr{"kind"}, []SwitchCase{{StrExpr{"doc"}, UintExpr{1}}, {VariableRefExpr{"missing"}, UintExpr{2}}}, nil}, map[string]interface{}{"kind": "doc"}, uint(1), nil},
+		{SwitchExpr{VariableRefExpr{"kind"}, []SwitchCase{{StrExpr{"doc"}, VariableRefExpr{"missing"}}}, UintExpr{0}}, map[string]interface{}{"kind": "folder"}, uint(0), nil},
+		{SwitchExpr{VariableRefExpr{"kind"}, []SwitchCase{{UintExpr{1}, UintExpr{1}}}, UintExpr{0}}, map[string]interface{}{"kind": "folder"}, nil, errors.New("")},
+		{SwitchExpr{VariableRefExpr{"level"}, []SwitchCase{{UintExpr{1}, StrExpr{"low"}}, {FloatExpr{2}, StrExpr{"high"}}}, nil}, map[string]interface{}{"level": int8(2)}, "high", nil},
+	}
+	for _, d := range data {
+		t.Run(fmt.Sprintf("%v", d.input), func(t *testing.T) {
+			ev := Evaluator{}
+
+			got, err := ev.Eval(d.input, d.env)
+			if err != nil {
+				if d.expectError == nil {
+					t.Fatalf("unexpected error: %v", err)
+				} else {
+					return
+				}
+			}
+
+			if d.expectError != nil {
+				t.Fatalf("expected error: %v", d.expectError)
+			}
+
+			if got != d.want {
+				t.Fatalf("got %v (%T), want %v (%T)", got, got, d.want, d.want)
+			}
+		})
+	}
+}
+
+// Evaluator can evaluate local binding expressions.
+func TestEvalLet(t *testing.T) {
+	data := []struct {
+		input       Expr
+		env         map[string]interface{}
+		want        interface{}
+		expectError error
+	}{
+		{LetExpr{"org", StructFieldRefExpr{VarName: "user", Path: []PathSegment{{Name: "Org"}, {Name: "ID"}}}, EqExpr{VariableRefExpr{"org"}, StrExpr{"acme"}}}, map[string]interface{}{"user": map[string]interface{}{"Org": map[string]string{"ID": "acme"}}}, true, nil},
+		{LetExpr{"x", StrExpr{"inner"}, VariableRefExpr{"x"}}, map[string]interface{}{"x": "outer"}, "inner", nil},
+		{LetExpr{"x", UintExpr{1}, LetExpr{"x", AddExpr{VariableRefExpr{"x"}, UintExpr{1}}, VariableRefExpr{"x"}}}, nil, uint(2), nil},
+		{LetExpr{"x", UintExpr{1}, LetExpr{"y", UintExpr{2}, VariableRefExpr{"x"}}}, nil, uint(1), nil},
+		{LetExpr{"x", VariableRefExpr{"x"}, VariableRefExpr{"x"}}, nil, nil, errors.New("")},
+		{LetExpr{"x", NullExpr{}, ExistsExpr{VariableRefExpr{"x"}}}, nil, false, nil},
+		{LetExpr{"m", StructFieldRefExpr{VarName: "user", Path: []PathSegment{{Name: "Manager"}}}, StructFieldRefExpr{VarName: "m", Path: []PathSegment{{Name: "ID"}}}}, map[string]interface{}{"user": &testEmployee{ID: 1, Manager: &testEmployee{ID: 2}}}, uint(2), nil},
+		{LetExpr{"x", VariableRefExpr{"missing"}, TrueExpr{}}, nil, nil, errors.New("")},
+		{AnyExpr{VariableRefExpr{"ids"}, "id", LetExpr{"limit", UintExpr{2}, GtExpr{VariableRefExpr{"id"}, VariableRefExpr{"limit"}}}}, map[string]interface{}{"ids": []int{1, 3}}, true, nil},
+	}
+	for _, d := range data {
+		t.Run(fmt.Sprintf("%v", d.input), func(t *testing.T) {
+			ev := Evaluator{}
+
+			got, err := ev.Eval(d.input, d.env)
+			if err != nil {
+				if d.expectError == nil {
+					t.Fatalf("unexpected error: %v", err)
+				} else {
+					return
+				}
+			}
+
+			if d.expectError != nil {
+				t.Fatalf("expected error: %v", d.expectError)
+			}
+
+			if got != d.want {
+				t.Fatalf("got %v (%T), want %v (%T)", got, got, d.want, d.want)
+			}
+		})
+	}
+}
+
+// Evaluator does not leak variables bound by $let() into the environment.
+func TestEvalLetScope(t *testing.T) {
+	env := map[string]interface{}{"x": "outer"}
+
+	ev := Evaluator{}
+	if _, err := ev.Eval(LetExpr{"x", StrExpr{"inner"}, VariableRefExpr{"x"}}, env); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := ev.Eval(LetExpr{"y", StrExpr{"inner"}, VariableRefExpr{"y"}}, env); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(env) != 1 || env["x"] != "outer" {
+		t.Fatalf("got %v, want %v", env, map[string]interface{}{"x": "outer"})
+	}
+}
+
+// A child scope holds only its own bindings, and falls back to the enclosing
+// scopes for any other variable.
+func TestChildScope(t *testing.T) {
+	forged := map[string]interface{}{"z": "forged"}
+	env := map[string]interface{}{"x": "outer", "y": "outer", parentScopeKey: forged}
+
+	scope := childScope(env)
+	scope["x"] = "inner"
+	nested := childScope(scope)
+	nested["w"] = nil
+
+	if len(nested) != 2 {
+		t.Fatalf("got %d entries, want 2", len(nested))
+	}
+
+	data := []struct {
+		name  string
+		want  interface{}
+		found bool
+	}{
+		{"w", nil, true},
+		{"x", "inner", true},
+		{"y", "outer", true},
+		{"z", nil, false},
+		{parentScopeKey, forged, true},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			got, found := lookupVariable(nested, d.name)
+			if found != d.found || !reflect.DeepEqual(got, d.want) {
+				t.Fatalf("got (%v, %v), want (%v, %v)", got, found, d.want, d.found)
+			}
+		})
+	}
+}
+
+// Evaluator can evaluate string function expressions.
+func TestEvalStringFunctions(t *testing.T) {
+	data := []struct {
+		input       Expr
+		env         map[string]interface{}
+		want        interface{}
+		expectError error
+	}{
+		{LowerExpr{StrExpr{"Alice@Corp.COM"}}, nil, "alice@corp.com", nil},
+		{UpperExpr{VariableRefExpr{"role"}}, map[string]interface{}{"role": testRole("admin")}, "ADMIN", nil},
+		{TrimExpr{StrExpr{" \tadmin\n"}}, nil, "admin", nil},
+		{LowerExpr{UintExpr{1}}, nil, nil, errors.New("")},
+		{ConcatExpr{[]Expr{StrExpr{"org/"}, VariableRefExpr{"org"}}}, map[string]interface{}{"org": "acme"}, "org/acme", nil},
+		{ConcatExpr{[]Expr{StrExpr{"org/"}, VariableRefExpr{"org"}}}, map[string]interface{}{"org": 7}, nil, errors.New("")},
+		{ReplaceExpr{StrExpr{"a-b-c"}, StrExpr{"-"}, StrExpr{"_"}}, nil, "a_b_c", nil},
+		{ReplaceExpr{StrExpr{"a-b-c"}, StrExpr{"-"}, NullExpr{}}, nil, nil, errors.New("")},
+		{EqFoldExpr{VariableRefExpr{"group"}, StrExpr{"Admins"}}, map[string]interface{}{"group": "ADMINS"}, true, nil},
+		{EqFoldExpr{VariableRefExpr{"group"}, StrExpr{"Admins"}}, map[string]interface{}{"group": "admin"}, false, nil},
+		{EqFoldExpr{StrExpr{"straße"}, StrExpr{"STRASSE"}}, nil, false, nil},
+		{EqFoldExpr{StrExpr{"ß"}, StrExpr{"SS"}}, nil, false, nil},
+		{EqFoldExpr{StrExpr{"ß"}, StrExpr{"ẞ"}}, nil, true, nil},
+		{EqFoldExpr{StrExpr{"Σ"}, StrExpr{"ς"}}, nil, true, nil},
+		{EqFoldExpr{StrExpr{"1"}, UintExpr{1}}, nil, nil, errors.New("")},
+		{LenExpr{SplitExpr{StrExpr{"a,b,c"}, StrExpr{","}}}, nil, uint(3), nil},
+		{InExpr{StrExpr{"b"}, SplitExpr{VariableRefExpr{"groups"}, StrExpr{","}}}, map[string]interface{}{"groups": "a,b"}, true, nil},
+		{SplitExpr{StrExpr{"a"}, UintExpr{1}}, nil, nil, errors.New("")},
+	}
+	for _, d := range data {
+		t.Run(fmt.Sprintf("%v", d.input), func(t *testing.T) {
+			ev := Evaluator{}
+
+			got, err := ev.Eval(d.input, d.env)
+			if err != nil {
+				if d.expectError == nil {
+					t.Fatalf("unexpected error: %v", err)
+				} else {
+					return
+				}
+			}
+
+			if d.expectError != nil {
+				t.Fatalf("expected error: %v", d.expectError)
+			}
+
+			if got != d.want {
+				t.Fatalf("got %v (%T), want %v (%T)", got, got, d.want, d.want)
+			}
+		})
 	}
 }
@@ -3,6 +3,12 @@ package authz
 import (
 	"errors"
 	"fmt"
+	"net/netip"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
 )
 
 type Expr interface {
@@ -44,6 +50,23 @@ func (f FalseExpr) Equal(other Expr) bool {
 	return ok
 }
 
+// ----------------------------------------------------------------------------
+// NullExpr
+// ----------------------------------------------------------------------------
+
+// NullExpr represents the literal `null`, which evaluates to nil.
+type NullExpr struct {
+}
+
+func (n NullExpr) Eval(env map[string]interface{}) (interface{}, error) {
+	return nil, nil
+}
+
+func (n NullExpr) Equal(other Expr) bool {
+	_, ok := other.(NullExpr)
+	return ok
+}
+
 // ----------------------------------------------------------------------------
 // StrExpr
 // ----------------------------------------------------------------------------
@@ -88,6 +111,116 @@ func (i UintExpr) Equal(other Expr) bool {
 	return i.Value == otherInt.Value
 }
 
+// ----------------------------------------------------------------------------
+// IntExpr
+// ----------------------------------------------------------------------------
+
+// IntExpr represents a signed integer literal.
+type IntExpr struct {
+	Value int64
+}
+
+func (i IntExpr) Eval(env map[string]interface{}) (interface{}, error) {
+	return i.Value, nil
+}
+
+func (i IntExpr) Equal(other Expr) bool {
+	otherInt, ok := other.(IntExpr)
+	if !ok {
+		return false
+	}
+
+	return i.Value == otherInt.Value
+}
+
+// ----------------------------------------------------------------------------
+// FloatExpr
+// ----------------------------------------------------------------------------
+
+// FloatExpr represents a floating point literal.
+type FloatExpr struct {
+	Value float64
+}
+
+func (f FloatExpr) Eval(env map[string]interface{}) (interface{}, error) {
+	return f.Value, nil
+}
+
+func (f FloatExpr) Equal(other Expr) bool {
+	otherFloat, ok := other.(FloatExpr)
+	if !ok {
+		return false
+	}
+
+	return f.Value == otherFloat.Value
+}
+
+// ----------------------------------------------------------------------------
+// TimeExpr
+// ----------------------------------------------------------------------------
+
+// TimeExpr represents a timestamp literal.
+type TimeExpr struct {
+	Value time.Time
+}
+
+func (t TimeExpr) Eval(env map[string]interface{}) (interface{}, error) {
+	return t.Value, nil
+}
+
+func (t TimeExpr) Equal(other Expr) bool {
+	otherTime, ok := other.(TimeExpr)
+	if !ok {
+		return false
+	}
+
+	return t.Value.Equal(otherTime.Value)
+}
+
+// ----------------------------------------------------------------------------
+// DurationExpr
+// ----------------------------------------------------------------------------
+
+// DurationExpr represents a duration literal.
+type DurationExpr struct {
+	Value time.Duration
+}
+
+func (d DurationExpr) Eval(env map[string]interface{}) (interface{}, error) {
+	return d.Value, nil
+}
+
+func (d DurationExpr) Equal(other Expr) bool {
+	otherDuration, ok := other.(DurationExpr)
+	if !ok {
+		return false
+	}
+
+	return d.Value == otherDuration.Value
+}
+
+// ----------------------------------------------------------------------------
+// NowExpr
+// ----------------------------------------------------------------------------
+
+// NowExpr represents the current time, as reported by a clock.
+type NowExpr struct {
+	// The clock used to determine the current time; if nil, time.Now is used.
+	Clock func() time.Time
+}
+
+func (n NowExpr) Eval(env map[string]interface{}) (interface{}, error) {
+	if n.Clock == nil {
+		return time.Now(), nil
+	}
+	return n.Clock(), nil
+}
+
+func (n NowExpr) Equal(other Expr) bool {
+	_, ok := other.(NowExpr)
+	return ok
+}
+
 // ----------------------------------------------------------------------------
 // BoolSliceExpr
 // ----------------------------------------------------------------------------
@@ -220,6 +353,70 @@ func (u UintSliceExpr) Equal(other Expr) bool {
 	return true
 }
 
+// ----------------------------------------------------------------------------
+// GlobSliceExpr
+// ----------------------------------------------------------------------------
+
+// Represents a glob pattern slice literal.
+type GlobSliceExpr struct {
+	Patterns []Glob
+}
+
+func (g GlobSliceExpr) Eval(env map[string]interface{}) (interface{}, error) {
+	return g.Patterns, nil
+}
+
+func (g GlobSliceExpr) Equal(other Expr) bool {
+	otherGlobSlice, ok := other.(GlobSliceExpr)
+	if !ok {
+		return false
+	}
+
+	if len(g.Patterns) != len(otherGlobSlice.Patterns) {
+		return false
+	}
+
+	for i, pattern := range g.Patterns {
+		if pattern.String() != otherGlobSlice.Patterns[i].String() {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ----------------------------------------------------------------------------
+// CIDRSliceExpr
+// ----------------------------------------------------------------------------
+
+// Represents a CIDR network slice literal.
+type CIDRSliceExpr struct {
+	Prefixes []netip.Prefix
+}
+
+func (c CIDRSliceExpr) Eval(env map[string]interface{}) (interface{}, error) {
+	return c.Prefixes, nil
+}
+
+func (c CIDRSliceExpr) Equal(other Expr) bool {
+	otherCIDRSlice, ok := other.(CIDRSliceExpr)
+	if !ok {
+		return false
+	}
+
+	if len(c.Prefixes) != len(otherCIDRSlice.Prefixes) {
+		return false
+	}
+
+	for i, prefix := range c.Prefixes {
+		if prefix != otherCIDRSlice.Prefixes[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
 // ----------------------------------------------------------------------------
 // EqExpr
 // ----------------------------------------------------------------------------
@@ -240,37 +437,12 @@ func (e EqExpr) Eval(env map[string]interface{}) (interface{}, error) {
 		return false, err
 	}
 
-	asStr, err := coerceStr(left)
-	if err == nil {
-		rAsStr, err := coerceStr(right)
-		if err == nil {
-			return asStr == rAsStr, nil
-		} else {
-			return nil, fmt.Errorf("mismatched types in equality comparison: %T != %T", left, right)
-		}
-	}
-
-	asUint, err := coerceUint(left)
-	if err == nil {
-		rAsUint, err := coerceUint(right)
-		if err == nil {
-			return asUint == rAsUint, nil
-		} else {
-			return nil, fmt.Errorf("mismatched types in equality comparison: %T != %T", left, right)
-		}
-	}
-
-	asBool, err := coerceBool(left)
-	if err == nil {
-		rAsBool, err := coerceBool(right)
-		if err == nil {
-			return asBool == rAsBool, nil
-		} else {
-			return nil, fmt.Errorf("mismatched types in equality comparison: %T != %T", left, right)
-		}
+	eq, err := compareEqual(left, right)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil, fmt.Errorf("unsupported type in equality comparison: %T", left)
+	return eq, nil
 }
 
 func (e EqExpr) Equal(other Expr) bool {
@@ -283,231 +455,1650 @@ func (e EqExpr) Equal(other Expr) bool {
 }
 
 // ----------------------------------------------------------------------------
-// AndExpr
+// NeExpr
 // ----------------------------------------------------------------------------
 
-type AndExpr struct {
-	Exprs []Expr
+// NeExpr represents an inequality comparison. It follows the same typing
+// rules as EqExpr.
+type NeExpr struct {
+	Left  Expr
+	Right Expr
 }
 
-func (a AndExpr) Eval(env map[string]interface{}) (interface{}, error) {
-	for _, expr := range a.Exprs {
-		r, err := expr.Eval(env)
-		if err != nil {
-			return false, err
-		}
-
-		ok, err := truthy(r)
-		if err != nil {
-			return false, err
-		}
+func (n NeExpr) Eval(env map[string]interface{}) (interface{}, error) {
+	left, err := n.Left.Eval(env)
+	if err != nil {
+		return false, err
+	}
+	right, err := n.Right.Eval(env)
+	if err != nil {
+		return false, err
+	}
 
-		if !ok {
-			return false, nil
-		}
+	eq, err := compareEqual(left, right)
+	if err != nil {
+		return nil, err
 	}
 
-	return true, nil
+	return !eq, nil
 }
 
-func (a AndExpr) Equal(other Expr) bool {
-	otherAnd, ok := other.(AndExpr)
+func (n NeExpr) Equal(other Expr) bool {
+	otherNe, ok := other.(NeExpr)
 	if !ok {
 		return false
 	}
 
-	if len(a.Exprs) != len(otherAnd.Exprs) {
-		return false
-	}
-
-	for i, expr := range a.Exprs {
-		if !expr.Equal(otherAnd.Exprs[i]) {
-			return false
-		}
-	}
-
-	return true
+	return n.Left.Equal(otherNe.Left) && n.Right.Equal(otherNe.Right)
 }
 
 // ----------------------------------------------------------------------------
-// OrExpr
+// Ordering Comparisons
 // ----------------------------------------------------------------------------
 
-type OrExpr struct {
-	Exprs []Expr
+// LtExpr represents a less-than comparison.
+type LtExpr struct {
+	Left  Expr
+	Right Expr
 }
 
-func (o OrExpr) Eval(env map[string]interface{}) (interface{}, error) {
-	for _, expr := range o.Exprs {
-		r, err := expr.Eval(env)
-		if err != nil {
-			return false, err
-		}
-
-		ok, err := truthy(r)
-		if err != nil {
-			return false, err
-		}
-
-		if ok {
-			return true, nil
-		}
+func (l LtExpr) Eval(env map[string]interface{}) (interface{}, error) {
+	c, err := evalOrdering(env, l.Left, l.Right)
+	if err != nil {
+		return false, err
 	}
-
-	return false, nil
+	return c < 0, nil
 }
 
-func (o OrExpr) Equal(other Expr) bool {
-	otherOr, ok := other.(OrExpr)
+func (l LtExpr) Equal(other Expr) bool {
+	otherLt, ok := other.(LtExpr)
 	if !ok {
 		return false
 	}
 
-	if len(o.Exprs) != len(otherOr.Exprs) {
-		return false
+	return l.Left.Equal(otherLt.Left) && l.Right.Equal(otherLt.Right)
+}
+
+// LteExpr represents a less-than-or-equal comparison.
+type LteExpr struct {
+	Left  Expr
+	Right Expr
+}
+
+func (l LteExpr) Eval(env map[string]interface{}) (interface{}, error) {
+	c, err := evalOrdering(env, l.Left, l.Right)
+	if err != nil {
+		return false, err
 	}
+	return c <= 0, nil
+}
 
-	for i, expr := range o.Exprs {
-		if !expr.Equal(otherOr.Exprs[i]) {
-			return false
-		}
+func (l LteExpr) Equal(other Expr) bool {
+	otherLte, ok := other.(LteExpr)
+	if !ok {
+		return false
 	}
 
-	return true
+	return l.Left.Equal(otherLte.Left) && l.Right.Equal(otherLte.Right)
 }
 
-// ----------------------------------------------------------------------------
-// VariableRefExpr
-// ----------------------------------------------------------------------------
-
-// An expression that represents a variable reference.
-type VariableRefExpr struct {
-	// The name of the referenced variable
-	Name string
+// GtExpr represents a greater-than comparison.
+type GtExpr struct {
+	Left  Expr
+	Right Expr
 }
 
-func (v VariableRefExpr) Eval(params map[string]interface{}) (interface{}, error) {
-	if _, ok := params[v.Name]; !ok {
-		return nil, fmt.Errorf("variable %s not found", v.Name)
+func (g GtExpr) Eval(env map[string]interface{}) (interface{}, error) {
+	c, err := evalOrdering(env, g.Left, g.Right)
+	if err != nil {
+		return false, err
 	}
-	return params[v.Name], nil
+	return c > 0, nil
 }
 
-func (v VariableRefExpr) Equal(other Expr) bool {
-	otherValue, ok := other.(VariableRefExpr)
+func (g GtExpr) Equal(other Expr) bool {
+	otherGt, ok := other.(GtExpr)
 	if !ok {
 		return false
 	}
 
-	return v.Name == otherValue.Name
+	return g.Left.Equal(otherGt.Left) && g.Right.Equal(otherGt.Right)
 }
 
-// ----------------------------------------------------------------------------
-// StructFieldRefExpr
-// ----------------------------------------------------------------------------
-
-// An expression that represents a struct field reference.
-type StructFieldRefExpr struct {
-	// The name of the referenced variable
-	VarName string
-	// The name of the field
-	FieldName string
+// GteExpr represents a greater-than-or-equal comparison.
+type GteExpr struct {
+	Left  Expr
+	Right Expr
 }
 
-func (s StructFieldRefExpr) Eval(params map[string]interface{}) (interface{}, error) {
-	if _, ok := params[s.VarName]; !ok {
-		return nil, fmt.Errorf("variable %s not found", s.VarName)
+func (g GteExpr) Eval(env map[string]interface{}) (interface{}, error) {
+	c, err := evalOrdering(env, g.Left, g.Right)
+	if err != nil {
+		return false, err
 	}
-
-	return GetField(params[s.VarName], s.FieldName)
+	return c >= 0, nil
 }
 
-func (s StructFieldRefExpr) Equal(other Expr) bool {
-	otherValue, ok := other.(StructFieldRefExpr)
+func (g GteExpr) Equal(other Expr) bool {
+	otherGte, ok := other.(GteExpr)
 	if !ok {
 		return false
 	}
 
-	return s.VarName == otherValue.VarName && s.FieldName == otherValue.FieldName
+	return g.Left.Equal(otherGte.Left) && g.Right.Equal(otherGte.Right)
+}
+
+// Evaluate both operands of an ordering comparison and compare them.
+func evalOrdering(env map[string]interface{}, leftExpr Expr, rightExpr Expr) (int, error) {
+	left, err := leftExpr.Eval(env)
+	if err != nil {
+		return 0, err
+	}
+	right, err := rightExpr.Eval(env)
+	if err != nil {
+		return 0, err
+	}
+
+	return compareOrdered(left, right)
 }
 
 // ----------------------------------------------------------------------------
-// InExpr
+// String Predicates
 // ----------------------------------------------------------------------------
 
-// An expression that represents the logic to determine if an element is a member of a slice.
-type InExpr struct {
-	Element    Expr
-	Collection Expr
+// StartsWithExpr determines if a string begins with a prefix.
+type StartsWithExpr struct {
+	Value  Expr
+	Prefix Expr
+}
+
+func (s StartsWithExpr) Eval(env map[string]interface{}) (interface{}, error) {
+	value, prefix, err := evalStrPair(env, s.Value, s.Prefix, "$startsWith")
+	if err != nil {
+		return false, err
+	}
+	return strings.HasPrefix(value, prefix), nil
+}
+
+func (s StartsWithExpr) Equal(other Expr) bool {
+	otherStartsWith, ok := other.(StartsWithExpr)
+	if !ok {
+		return false
+	}
+
+	return s.Value.Equal(otherStartsWith.Value) && s.Prefix.Equal(otherStartsWith.Prefix)
+}
+
+// EndsWithExpr determines if a string ends with a suffix.
+type EndsWithExpr struct {
+	Value  Expr
+	Suffix Expr
+}
+
+func (e EndsWithExpr) Eval(env map[string]interface{}) (interface{}, error) {
+	value, suffix, err := evalStrPair(env, e.Value, e.Suffix, "$endsWith")
+	if err != nil {
+		return false, err
+	}
+	return strings.HasSuffix(value, suffix), nil
+}
+
+func (e EndsWithExpr) Equal(other Expr) bool {
+	otherEndsWith, ok := other.(EndsWithExpr)
+	if !ok {
+		return false
+	}
+
+	return e.Value.Equal(otherEndsWith.Value) && e.Suffix.Equal(otherEndsWith.Suffix)
+}
+
+// ContainsExpr determines if a string contains a substring.
+type ContainsExpr struct {
+	Value     Expr
+	Substring Expr
+}
+
+func (c ContainsExpr) Eval(env map[string]interface{}) (interface{}, error) {
+	value, substring, err := evalStrPair(env, c.Value, c.Substring, "$contains")
+	if err != nil {
+		return false, err
+	}
+	return strings.Contains(value, substring), nil
+}
+
+func (c ContainsExpr) Equal(other Expr) bool {
+	otherContains, ok := other.(ContainsExpr)
+	if !ok {
+		return false
+	}
+
+	return c.Value.Equal(otherContains.Value) && c.Substring.Equal(otherContains.Substring)
+}
+
+// MatchesExpr determines if a string matches a regular expression. The
+// pattern uses RE2 syntax and is unanchored.
+type MatchesExpr struct {
+	Value   Expr
+	Pattern *regexp.Regexp
+}
+
+func (m MatchesExpr) Eval(env map[string]interface{}) (interface{}, error) {
+	val, err := m.Value.Eval(env)
+	if err != nil {
+		return false, err
+	}
+
+	str, err := coerceStr(val)
+	if err != nil {
+		return false, fmt.Errorf("unexpected type for $matches(): %w", err)
+	}
+
+	return m.Pattern.MatchString(str), nil
+}
+
+func (m MatchesExpr) Equal(other Expr) bool {
+	otherMatches, ok := other.(MatchesExpr)
+	if !ok {
+		return false
+	}
+
+	return m.Value.Equal(otherMatches.Value) && m.Pattern.String() == otherMatches.Pattern.String()
+}
+
+// GlobExpr determines if a path matches a glob pattern.
+type GlobExpr struct {
+	Value   Expr
+	Pattern Glob
+}
+
+func (g GlobExpr) Eval(env map[string]interface{}) (interface{}, error) {
+	val, err := g.Value.Eval(env)
+	if err != nil {
+		return false, err
+	}
+
+	str, err := coerceStr(val)
+	if err != nil {
+		return false, fmt.Errorf("unexpected type for $glob(): %w", err)
+	}
+
+	return g.Pattern.Match(str), nil
+}
+
+func (g GlobExpr) Equal(other Expr) bool {
+	otherGlob, ok := other.(GlobExpr)
+	if !ok {
+		return false
+	}
+
+	return g.Value.Equal(otherGlob.Value) && g.Pattern.String() == otherGlob.Pattern.String()
+}
+
+// IPInCIDRExpr determines if an IP address belongs to a network.
+type IPInCIDRExpr struct {
+	IP     Expr
+	Prefix netip.Prefix
+}
+
+func (i IPInCIDRExpr) Eval(env map[string]interface{}) (interface{}, error) {
+	val, err := i.IP.Eval(env)
+	if err != nil {
+		return false, err
+	}
+
+	addr, err := coerceAddr(val)
+	if err != nil {
+		return false, fmt.Errorf("unexpected value for $ipInCidr(): %w", err)
+	}
+
+	return i.Prefix.Contains(addr), nil
+}
+
+func (i IPInCIDRExpr) Equal(other Expr) bool {
+	otherIPInCIDR, ok := other.(IPInCIDRExpr)
+	if !ok {
+		return false
+	}
+
+	return i.IP.Equal(otherIPInCIDR.IP) && i.Prefix == otherIPInCIDR.Prefix
+}
+
+// Evaluate a pair of expressions that must both produce strings.
+func evalStrPair(env map[string]interface{}, leftExpr Expr, rightExpr Expr, op string) (string, string, error) {
+	lStr, err := evalStr(env, leftExpr, op)
+	if err != nil {
+		return "", "", err
+	}
+	rStr, err := evalStr(env, rightExpr, op)
+	if err != nil {
+		return "", "", err
+	}
+
+	return lStr, rStr, nil
+}
+
+// Evaluate an operand of the given operator to a string.
+func evalStr(env map[string]interface{}, expr Expr, op string) (string, error) {
+	value, err := expr.Eval(env)
+	if err != nil {
+		return "", err
+	}
+
+	str, err := coerceStr(value)
+	if err != nil {
+		return "", fmt.Errorf("unexpected type for %s(): %w", op, err)
+	}
+
+	return str, nil
+}
+
+// ----------------------------------------------------------------------------
+// String Functions
+// ----------------------------------------------------------------------------
+
+// LowerExpr represents the conversion of a string to lower case.
+type LowerExpr struct {
+	Expr Expr
+}
+
+func (l LowerExpr) Eval(env map[string]interface{}) (interface{}, error) {
+	str, err := evalStr(env, l.Expr, "$lower")
+	if err != nil {
+		return nil, err
+	}
+	return strings.ToLower(str), nil
+}
+
+func (l LowerExpr) Equal(other Expr) bool {
+	otherLower, ok := other.(LowerExpr)
+	if !ok {
+		return false
+	}
+
+	return l.Expr.Equal(otherLower.Expr)
+}
+
+// UpperExpr represents the conversion of a string to upper case.
+type UpperExpr struct {
+	Expr Expr
+}
+
+func (u UpperExpr) Eval(env map[string]interface{}) (interface{}, error) {
+	str, err := evalStr(env, u.Expr, "$upper")
+	if err != nil {
+		return nil, err
+	}
+	return strings.ToUpper(str), nil
+}
+
+func (u UpperExpr) Equal(other Expr) bool {
+	otherUpper, ok := other.(UpperExpr)
+	if !ok {
+		return false
+	}
+
+	return u.Expr.Equal(otherUpper.Expr)
+}
+
+// TrimExpr represents the removal of leading and trailing whitespace from a
+// string.
+type TrimExpr struct {
+	Expr Expr
+}
+
+func (t TrimExpr) Eval(env map[string]interface{}) (interface{}, error) {
+	str, err := evalStr(env, t.Expr, "$trim")
+	if err != nil {
+		return nil, err
+	}
+	return strings.TrimSpace(str), nil
+}
+
+func (t TrimExpr) Equal(other Expr) bool {
+	otherTrim, ok := other.(TrimExpr)
+	if !ok {
+		return false
+	}
+
+	return t.Expr.Equal(otherTrim.Expr)
+}
+
+// ConcatExpr represents the concatenation of a sequence of strings.
+type ConcatExpr struct {
+	Exprs []Expr
+}
+
+func (c ConcatExpr) Eval(env map[string]interface{}) (interface{}, error) {
+	var b strings.Builder
+	for _, expr := range c.Exprs {
+		str, err := evalStr(env, expr, "$concat")
+		if err != nil {
+			return nil, err
+		}
+		b.WriteString(str)
+	}
+	return b.String(), nil
+}
+
+func (c ConcatExpr) Equal(other Expr) bool {
+	otherConcat, ok := other.(ConcatExpr)
+	if !ok {
+		return false
+	}
+
+	if len(c.Exprs) != len(otherConcat.Exprs) {
+		return false
+	}
+
+	for i, expr := range c.Exprs {
+		if !expr.Equal(otherConcat.Exprs[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// SplitExpr represents the division of a string into a slice of the
+// substrings between each instance of a separator; see strings.Split.
+type SplitExpr struct {
+	Value     Expr
+	Separator Expr
+}
+
+func (s SplitExpr) Eval(env map[string]interface{}) (interface{}, error) {
+	str, sep, err := evalStrPair(env, s.Value, s.Separator, "$split")
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(str, sep), nil
+}
+
+func (s SplitExpr) Equal(other Expr) bool {
+	otherSplit, ok := other.(SplitExpr)
+	if !ok {
+		return false
+	}
+
+	return s.Value.Equal(otherSplit.Value) && s.Separator.Equal(otherSplit.Separator)
+}
+
+// ReplaceExpr represents the replacement of every instance of a substring of
+// a string with another string; see strings.ReplaceAll.
+type ReplaceExpr struct {
+	Value Expr
+	Old   Expr
+	New   Expr
+}
+
+func (r ReplaceExpr) Eval(env map[string]interface{}) (interface{}, error) {
+	str, old, err := evalStrPair(env, r.Value, r.Old, "$replace")
+	if err != nil {
+		return nil, err
+	}
+	replacement, err := evalStr(env, r.New, "$replace")
+	if err != nil {
+		return nil, err
+	}
+	return strings.ReplaceAll(str, old, replacement), nil
+}
+
+func (r ReplaceExpr) Equal(other Expr) bool {
+	otherReplace, ok := other.(ReplaceExpr)
+	if !ok {
+		return false
+	}
+
+	return r.Value.Equal(otherReplace.Value) && r.Old.Equal(otherReplace.Old) && r.New.Equal(otherReplace.New)
+}
+
+// EqFoldExpr represents a case-insensitive equality comparison of strings,
+// under simple Unicode case folding as by strings.EqualFold. Only
+// one-to-one mappings between runes are applied, so e.g. 'ß' is not equal
+// to 'SS', although 'ß' and 'ẞ' are equal.
+type EqFoldExpr struct {
+	Left  Expr
+	Right Expr
+}
+
+func (e EqFoldExpr) Eval(env map[string]interface{}) (interface{}, error) {
+	left, right, err := evalStrPair(env, e.Left, e.Right, "$eqFold")
+	if err != nil {
+		return false, err
+	}
+	return strings.EqualFold(left, right), nil
+}
+
+func (e EqFoldExpr) Equal(other Expr) bool {
+	otherEqFold, ok := other.(EqFoldExpr)
+	if !ok {
+		return false
+	}
+
+	return e.Left.Equal(otherEqFold.Left) && e.Right.Equal(otherEqFold.Right)
+}
+
+// ----------------------------------------------------------------------------
+// Arithmetic
+// ----------------------------------------------------------------------------
+
+// AddExpr represents an addition of numbers, or of times and durations; see
+// addValues.
+type AddExpr struct {
+	Left  Expr
+	Right Expr
+}
+
+func (a AddExpr) Eval(env map[string]interface{}) (interface{}, error) {
+	left, err := a.Left.Eval(env)
+	if err != nil {
+		return nil, err
+	}
+	right, err := a.Right.Eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	return addValues(left, right)
+}
+
+func (a AddExpr) Equal(other Expr) bool {
+	otherAdd, ok := other.(AddExpr)
+	if !ok {
+		return false
+	}
+
+	return a.Left.Equal(otherAdd.Left) && a.Right.Equal(otherAdd.Right)
+}
+
+// SubExpr represents a subtraction of numbers, or of times and durations; see
+// subValues.
+type SubExpr struct {
+	Left  Expr
+	Right Expr
+}
+
+func (s SubExpr) Eval(env map[string]interface{}) (interface{}, error) {
+	left, err := s.Left.Eval(env)
+	if err != nil {
+		return nil, err
+	}
+	right, err := s.Right.Eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	return subValues(left, right)
+}
+
+func (s SubExpr) Equal(other Expr) bool {
+	otherSub, ok := other.(SubExpr)
+	if !ok {
+		return false
+	}
+
+	return s.Left.Equal(otherSub.Left) && s.Right.Equal(otherSub.Right)
+}
+
+// MulExpr represents a multiplication of numbers.
+type MulExpr struct {
+	Left  Expr
+	Right Expr
+}
+
+func (m MulExpr) Eval(env map[string]interface{}) (interface{}, error) {
+	left, err := m.Left.Eval(env)
+	if err != nil {
+		return nil, err
+	}
+	right, err := m.Right.Eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	return mulValues(left, right)
+}
+
+func (m MulExpr) Equal(other Expr) bool {
+	otherMul, ok := other.(MulExpr)
+	if !ok {
+		return false
+	}
+
+	return m.Left.Equal(otherMul.Left) && m.Right.Equal(otherMul.Right)
+}
+
+// DivExpr represents a division of numbers. Integer division truncates toward
+// zero.
+type DivExpr struct {
+	Left  Expr
+	Right Expr
+}
+
+func (d DivExpr) Eval(env map[string]interface{}) (interface{}, error) {
+	left, err := d.Left.Eval(env)
+	if err != nil {
+		return nil, err
+	}
+	right, err := d.Right.Eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	return divValues(left, right)
+}
+
+func (d DivExpr) Equal(other Expr) bool {
+	otherDiv, ok := other.(DivExpr)
+	if !ok {
+		return false
+	}
+
+	return d.Left.Equal(otherDiv.Left) && d.Right.Equal(otherDiv.Right)
+}
+
+// ModExpr represents the remainder of a division of numbers, which has the
+// sign of the dividend.
+type ModExpr struct {
+	Left  Expr
+	Right Expr
+}
+
+func (m ModExpr) Eval(env map[string]interface{}) (interface{}, error) {
+	left, err := m.Left.Eval(env)
+	if err != nil {
+		return nil, err
+	}
+	right, err := m.Right.Eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	return modValues(left, right)
+}
+
+func (m ModExpr) Equal(other Expr) bool {
+	otherMod, ok := other.(ModExpr)
+	if !ok {
+		return false
+	}
+
+	return m.Left.Equal(otherMod.Left) && m.Right.Equal(otherMod.Right)
+}
+
+// ----------------------------------------------------------------------------
+// AndExpr
+// ----------------------------------------------------------------------------
+
+type AndExpr struct {
+	Exprs []Expr
+}
+
+func (a AndExpr) Eval(env map[string]interface{}) (interface{}, error) {
+	for _, expr := range a.Exprs {
+		r, err := expr.Eval(env)
+		if err != nil {
+			return false, err
+		}
+
+		ok, err := truthy(r)
+		if err != nil {
+			return false, err
+		}
+
+		if !ok {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+func (a AndExpr) Equal(other Expr) bool {
+	otherAnd, ok := other.(AndExpr)
+	if !ok {
+		return false
+	}
+
+	if len(a.Exprs) != len(otherAnd.Exprs) {
+		return false
+	}
+
+	for i, expr := range a.Exprs {
+		if !expr.Equal(otherAnd.Exprs[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ----------------------------------------------------------------------------
+// OrExpr
+// ----------------------------------------------------------------------------
+
+type OrExpr struct {
+	Exprs []Expr
+}
+
+func (o OrExpr) Eval(env map[string]interface{}) (interface{}, error) {
+	for _, expr := range o.Exprs {
+		r, err := expr.Eval(env)
+		if err != nil {
+			return false, err
+		}
+
+		ok, err := truthy(r)
+		if err != nil {
+			return false, err
+		}
+
+		if ok {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (o OrExpr) Equal(other Expr) bool {
+	otherOr, ok := other.(OrExpr)
+	if !ok {
+		return false
+	}
+
+	if len(o.Exprs) != len(otherOr.Exprs) {
+		return false
+	}
+
+	for i, expr := range o.Exprs {
+		if !expr.Equal(otherOr.Exprs[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ----------------------------------------------------------------------------
+// NotExpr
+// ----------------------------------------------------------------------------
+
+// NotExpr represents the logical negation of an expression.
+type NotExpr struct {
+	Expr Expr
+}
+
+func (n NotExpr) Eval(env map[string]interface{}) (interface{}, error) {
+	r, err := n.Expr.Eval(env)
+	if err != nil {
+		return false, err
+	}
+
+	ok, err := truthy(r)
+	if err != nil {
+		return false, err
+	}
+
+	return !ok, nil
+}
+
+func (n NotExpr) Equal(other Expr) bool {
+	otherNot, ok := other.(NotExpr)
+	if !ok {
+		return false
+	}
+
+	return n.Expr.Equal(otherNot.Expr)
+}
+
+// ----------------------------------------------------------------------------
+// LetExpr
+// ----------------------------------------------------------------------------
+
+// LetExpr represents a local binding, e.g. `$let(org, user.Org.ID, body)`. The
+// value is evaluated once, and bound to a variable visible only in the body.
+// The variable shadows any parameter or enclosing binding of the same name,
+// but not within the value itself.
+type LetExpr struct {
+	// The name of the bound variable
+	Name string
+	// The value to bind
+	Value Expr
+	// The expression evaluated with the binding in scope
+	Body Expr
+}
+
+func (l LetExpr) Eval(env map[string]interface{}) (interface{}, error) {
+	value, err := l.Value.Eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	scope := childScope(env)
+	scope[l.Name] = value
+
+	return l.Body.Eval(scope)
+}
+
+func (l LetExpr) Equal(other Expr) bool {
+	otherLet, ok := other.(LetExpr)
+	if !ok {
+		return false
+	}
+
+	return l.Name == otherLet.Name && l.Value.Equal(otherLet.Value) && l.Body.Equal(otherLet.Body)
+}
+
+// ----------------------------------------------------------------------------
+// Conditionals
+// ----------------------------------------------------------------------------
+
+// IfExpr represents a conditional expression. Only the branch chosen by the
+// truthy-ness of the condition is evaluated, so the other branch may refer to
+// values that are missing.
+type IfExpr struct {
+	Cond Expr
+	Then Expr
+	Else Expr
+}
+
+func (i IfExpr) Eval(env map[string]interface{}) (interface{}, error) {
+	ok, err := evalPredicate(env, i.Cond)
+	if err != nil {
+		return nil, err
+	}
+
+	if ok {
+		return i.Then.Eval(env)
+	}
+	return i.Else.Eval(env)
+}
+
+func (i IfExpr) Equal(other Expr) bool {
+	otherIf, ok := other.(IfExpr)
+	if !ok {
+		return false
+	}
+
+	return i.Cond.Equal(otherIf.Cond) && i.Then.Equal(otherIf.Then) && i.Else.Equal(otherIf.Else)
+}
+
+// SwitchExpr represents a multi-way conditional expression. The result is that
+// of the first case whose value is equal to the subject, or else that of the
+// default. It is an error if no case matches and there is no default.
+//
+// Case values are evaluated in order until one matches, and only the chosen
+// result is evaluated.
+type SwitchExpr struct {
+	// The value compared with each case
+	Subject Expr
+	// The cases, in order
+	Cases []SwitchCase
+	// The default result, or nil if there is none
+	Default Expr
+}
+
+// A single case of a SwitchExpr.
+type SwitchCase struct {
+	// The value compared with the subject
+	Value Expr
+	// The result if the value matches
+	Result Expr
+}
+
+func (s SwitchExpr) Eval(env map[string]interface{}) (interface{}, error) {
+	subject, err := s.Subject.Eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, c := range s.Cases {
+		value, err := c.Value.Eval(env)
+		if err != nil {
+			return nil, err
+		}
+
+		eq, err := compareEqual(subject, value)
+		if err != nil {
+			return nil, fmt.Errorf("mismatched case in $switch(): %w", err)
+		}
+		if eq {
+			return c.Result.Eval(env)
+		}
+	}
+
+	if s.Default == nil {
+		return nil, fmt.Errorf("no case in $switch() matches %v", subject)
+	}
+	return s.Default.Eval(env)
+}
+
+func (s SwitchExpr) Equal(other Expr) bool {
+	otherSwitch, ok := other.(SwitchExpr)
+	if !ok {
+		return false
+	}
+
+	if !s.Subject.Equal(otherSwitch.Subject) || len(s.Cases) != len(otherSwitch.Cases) {
+		return false
+	}
+
+	for i, c := range s.Cases {
+		if !c.Value.Equal(otherSwitch.Cases[i].Value) || !c.Result.Equal(otherSwitch.Cases[i].Result) {
+			return false
+		}
+	}
+
+	if s.Default == nil || otherSwitch.Default == nil {
+		return s.Default == nil && otherSwitch.Default == nil
+	}
+	return s.Default.Equal(otherSwitch.Default)
+}
+
+// ----------------------------------------------------------------------------
+// ExistsExpr
+// ----------------------------------------------------------------------------
+
+// ExistsExpr represents a test for the presence of a variable, field, map key
+// or element. It is true if the reference resolves to a value other than null.
+type ExistsExpr struct {
+	// The reference to test; a VariableRefExpr or StructFieldRefExpr
+	Ref Expr
+}
+
+func (e ExistsExpr) Eval(env map[string]interface{}) (interface{}, error) {
+	value, err := e.Ref.Eval(env)
+	if isMissing(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	return !isNil(value), nil
+}
+
+func (e ExistsExpr) Equal(other Expr) bool {
+	otherExists, ok := other.(ExistsExpr)
+	if !ok {
+		return false
+	}
+
+	return e.Ref.Equal(otherExists.Ref)
+}
+
+// ----------------------------------------------------------------------------
+// CoalesceExpr
+// ----------------------------------------------------------------------------
+
+// CoalesceExpr represents the first of a sequence of expressions that
+// evaluates to a value other than null. Expressions that reference a missing
+// variable, field, map key or element are treated as null. If every
+// expression is null, the result is null.
+type CoalesceExpr struct {
+	Exprs []Expr
+}
+
+func (c CoalesceExpr) Eval(env map[string]interface{}) (interface{}, error) {
+	for _, expr := range c.Exprs {
+		value, err := expr.Eval(env)
+		if isMissing(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		if !isNil(value) {
+			return value, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (c CoalesceExpr) Equal(other Expr) bool {
+	otherCoalesce, ok := other.(CoalesceExpr)
+	if !ok {
+		return false
+	}
+
+	if len(c.Exprs) != len(otherCoalesce.Exprs) {
+		return false
+	}
+
+	for i, expr := range c.Exprs {
+		if !expr.Equal(otherCoalesce.Exprs[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Determine if an error reports that a referenced value is missing, either
+// because it does not exist or because it is reached through a nil value.
+func isMissing(err error) bool {
+	return errors.Is(err, ErrNotFound) || errors.Is(err, ErrNilDereference)
+}
+
+// ----------------------------------------------------------------------------
+// VariableRefExpr
+// ----------------------------------------------------------------------------
+
+// An expression that represents a variable reference.
+type VariableRefExpr struct {
+	// The name of the referenced variable
+	Name string
+}
+
+func (v VariableRefExpr) Eval(params map[string]interface{}) (interface{}, error) {
+	value, ok := lookupVariable(params, v.Name)
+	if !ok {
+		return nil, fmt.Errorf("variable %s %w", v.Name, ErrNotFound)
+	}
+	return value, nil
+}
+
+func (v VariableRefExpr) Equal(other Expr) bool {
+	otherValue, ok := other.(VariableRefExpr)
+	if !ok {
+		return false
+	}
+
+	return v.Name == otherValue.Name
+}
+
+// ----------------------------------------------------------------------------
+// StructFieldRefExpr
+// ----------------------------------------------------------------------------
+
+// An expression that represents a struct field reference, e.g. `user.Org.ID`.
+// Map keys and slice elements may also be referenced, e.g. `claims['groups'][0]`,
+// and optional segments short-circuit on nil values, e.g. `user?.Manager?.ID`.
+type StructFieldRefExpr struct {
+	// The name of the referenced variable
+	VarName string
+	// The path of fields to resolve, starting from the variable
+	Path []PathSegment
+	// Whether struct fields may also be named by their `json` tags
+	JSONTags bool
+}
+
+// A single step in the path of a struct field reference.
+type PathSegment struct {
+	// The name of the field or map key
+	Name string
+	// The slice or array index, if IsIndex is set
+	Index int
+	// Whether the segment is an index rather than a name
+	IsIndex bool
+	// Whether the segment is optional, written `?.name` or `?[index]`. If the
+	// value being accessed is nil, the whole reference evaluates to nil.
+	Optional bool
+}
+
+// Get the textual form of the segment, as it would appear after the
+// preceding part of a reference.
+func (p PathSegment) String() string {
+	var optional string
+	if p.Optional {
+		optional = "?"
+	}
+
+	if p.IsIndex {
+		return fmt.Sprintf("%s[%d]", optional, p.Index)
+	}
+	return optional + "." + p.Name
+}
+
+func (s StructFieldRefExpr) Eval(params map[string]interface{}) (interface{}, error) {
+	value, ok := lookupVariable(params, s.VarName)
+	if !ok {
+		return nil, fmt.Errorf("variable %s %w", s.VarName, ErrNotFound)
+	}
+
+	resolved := s.VarName
+	for _, segment := range s.Path {
+		if segment.Optional && isNil(value) {
+			return nil, nil
+		}
+
+		var next interface{}
+		var err error
+		if segment.IsIndex {
+			next, err = GetIndex(value, segment.Index)
+			if err != nil {
+				return nil, fmt.Errorf("failed to resolve index %d of %s: %w", segment.Index, resolved, err)
+			}
+		} else {
+			next, err = getField(value, segment.Name, s.JSONTags)
+			if err != nil {
+				return nil, fmt.Errorf("failed to resolve field %s of %s: %w", segment.Name, resolved, err)
+			}
+		}
+		value = next
+		resolved += segment.String()
+	}
+
+	return value, nil
+}
+
+func (s StructFieldRefExpr) Equal(other Expr) bool {
+	otherValue, ok := other.(StructFieldRefExpr)
+	if !ok {
+		return false
+	}
+
+	if s.VarName != otherValue.VarName || s.JSONTags != otherValue.JSONTags || len(s.Path) != len(otherValue.Path) {
+		return false
+	}
+
+	for i, segment := range s.Path {
+		if segment != otherValue.Path[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ----------------------------------------------------------------------------
+// InExpr
+// ----------------------------------------------------------------------------
+
+// An expression that represents the logic to determine if an element is a member of a
+// collection: a slice, an array, or the keys of a map.
+type InExpr struct {
+	Element    Expr
+	Collection Expr
 }
 
 func (i InExpr) Eval(params map[string]interface{}) (interface{}, error) {
 	// Resolve the collection
 	sliceVal, err := i.Collection.Eval(params)
 	if err != nil {
-		return nil, err
+		return nil, err
+	}
+
+	// Resolve the element
+	queryVal, err := i.Element.Eval(params)
+	if err != nil {
+		return nil, err
+	}
+
+	// A collection of glob patterns matches any path that satisfies one of them
+	if globs, ok := sliceVal.([]Glob); ok {
+		queryStr, err := coerceStr(queryVal)
+		if err != nil {
+			return nil, errors.New("mismatched types for $in()")
+		}
+		for _, g := range globs {
+			if g.Match(queryStr) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	// A collection of networks contains any address that belongs to one of them
+	if prefixes, ok := sliceVal.([]netip.Prefix); ok {
+		queryAddr, err := coerceAddr(queryVal)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected value for $in() query: %w", err)
+		}
+		for _, p := range prefixes {
+			if p.Contains(queryAddr) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	// Collections of strings, unsigned integers and bools are searched directly
+	// for a query of the same type
+	if found, ok := containsScalar(sliceVal, queryVal); ok {
+		return found, nil
+	}
+
+	// A map contains the query if one of its keys is equal to it
+	if rv := reflect.ValueOf(sliceVal); rv.Kind() == reflect.Map {
+		found, err := containsKey(rv, queryVal)
+		if err != nil {
+			return nil, fmt.Errorf("mismatched element in $in() collection: %w", err)
+		}
+		return found, nil
+	}
+
+	// Any other collection contains the query if one of its elements is equal
+	// to it. Every element must be comparable with the query.
+	elements, err := coerceCollection(sliceVal)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected value for $in() collection: %w", err)
 	}
 
-	// Resolve the element
-	queryVal, err := i.Element.Eval(params)
+	found, err := containsElement(elements, queryVal)
+	if err != nil {
+		return nil, fmt.Errorf("mismatched element in $in() collection: %w", err)
+	}
+
+	return found, nil
+}
+
+func (i InExpr) Equal(other Expr) bool {
+	otherIn, ok := other.(InExpr)
+	if !ok {
+		return false
+	}
+	return i.Element.Equal(otherIn.Element) && i.Collection.Equal(otherIn.Collection)
+}
+
+// ----------------------------------------------------------------------------
+// Set Relations
+// ----------------------------------------------------------------------------
+
+// SubsetExpr represents a test that every element of one collection is
+// contained in another.
+type SubsetExpr struct {
+	Left  Expr
+	Right Expr
+}
+
+func (s SubsetExpr) Eval(env map[string]interface{}) (interface{}, error) {
+	left, right, err := evalSetPair(env, s.Left, s.Right, "$subset")
+	if err != nil {
+		return false, err
+	}
+	return isSubset(left, right)
+}
+
+func (s SubsetExpr) Equal(other Expr) bool {
+	otherSubset, ok := other.(SubsetExpr)
+	if !ok {
+		return false
+	}
+
+	return s.Left.Equal(otherSubset.Left) && s.Right.Equal(otherSubset.Right)
+}
+
+// IntersectsExpr represents a test that two collections have at least one
+// element in common.
+type IntersectsExpr struct {
+	Left  Expr
+	Right Expr
+}
+
+func (i IntersectsExpr) Eval(env map[string]interface{}) (interface{}, error) {
+	left, right, err := evalSetPair(env, i.Left, i.Right, "$intersects")
+	if err != nil {
+		return false, err
+	}
+	return intersects(left, right)
+}
+
+func (i IntersectsExpr) Equal(other Expr) bool {
+	otherIntersects, ok := other.(IntersectsExpr)
+	if !ok {
+		return false
+	}
+
+	return i.Left.Equal(otherIntersects.Left) && i.Right.Equal(otherIntersects.Right)
+}
+
+// DisjointExpr represents a test that two collections have no elements in
+// common.
+type DisjointExpr struct {
+	Left  Expr
+	Right Expr
+}
+
+func (d DisjointExpr) Eval(env map[string]interface{}) (interface{}, error) {
+	left, right, err := evalSetPair(env, d.Left, d.Right, "$disjoint")
+	if err != nil {
+		return false, err
+	}
+
+	ok, err := intersects(left, right)
+	if err != nil {
+		return false, err
+	}
+	return !ok, nil
+}
+
+func (d DisjointExpr) Equal(other Expr) bool {
+	otherDisjoint, ok := other.(DisjointExpr)
+	if !ok {
+		return false
+	}
+
+	return d.Left.Equal(otherDisjoint.Left) && d.Right.Equal(otherDisjoint.Right)
+}
+
+// EqualSetsExpr represents a test that two collections contain the same
+// elements, ignoring order and duplicates.
+type EqualSetsExpr struct {
+	Left  Expr
+	Right Expr
+}
+
+func (e EqualSetsExpr) Eval(env map[string]interface{}) (interface{}, error) {
+	left, right, err := evalSetPair(env, e.Left, e.Right, "$equalSets")
+	if err != nil {
+		return false, err
+	}
+	return equalSets(left, right)
+}
+
+func (e EqualSetsExpr) Equal(other Expr) bool {
+	otherEqualSets, ok := other.(EqualSetsExpr)
+	if !ok {
+		return false
+	}
+
+	return e.Left.Equal(otherEqualSets.Left) && e.Right.Equal(otherEqualSets.Right)
+}
+
+// Evaluate both operands of a set relation to collections.
+func evalSetPair(env map[string]interface{}, leftExpr Expr, rightExpr Expr, op string) ([]interface{}, []interface{}, error) {
+	left, err := evalCollection(env, leftExpr, op)
+	if err != nil {
+		return nil, nil, err
+	}
+	right, err := evalCollection(env, rightExpr, op)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return left, right, nil
+}
+
+// Evaluate an operand of the given operator to a collection.
+func evalCollection(env map[string]interface{}, expr Expr, op string) ([]interface{}, error) {
+	value, err := expr.Eval(env)
 	if err != nil {
 		return nil, err
 	}
 
-	queryStr, err := coerceStr(queryVal)
-	if err == nil {
-		sliceStr, err := coerceStrSlice(sliceVal)
-		if err == nil {
-			for _, s := range sliceStr {
-				if s == queryStr {
-					return true, nil
-				}
-			}
-			return false, nil
-		} else {
-			return nil, errors.New("mismatched types for $in()")
+	collection, err := coerceCollection(value)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected value for %s(): %w", op, err)
+	}
+
+	return collection, nil
+}
+
+// ----------------------------------------------------------------------------
+// Quantifiers
+// ----------------------------------------------------------------------------
+
+// AnyExpr represents a test that at least one element of a collection
+// satisfies a predicate. The predicate is evaluated with each element bound
+// to a variable, which shadows any parameter of the same name.
+type AnyExpr struct {
+	// The collection whose elements are tested
+	Collection Expr
+	// The name of the variable bound to each element
+	Var string
+	// The predicate to evaluate for each element
+	Predicate Expr
+}
+
+func (a AnyExpr) Eval(env map[string]interface{}) (interface{}, error) {
+	elements, err := evalCollection(env, a.Collection, "$any")
+	if err != nil {
+		return false, err
+	}
+
+	scope := childScope(env)
+	for _, element := range elements {
+		scope[a.Var] = element
+		ok, err := evalPredicate(scope, a.Predicate)
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			return true, nil
 		}
 	}
 
-	queryUint, err := coerceUint(queryVal)
-	if err == nil {
-		sliceUint, err := coerceUintSlice(sliceVal)
-		if err == nil {
-			for _, u := range sliceUint {
-				if u == queryUint {
-					return true, nil
-				}
-			}
+	return false, nil
+}
+
+func (a AnyExpr) Equal(other Expr) bool {
+	otherAny, ok := other.(AnyExpr)
+	if !ok {
+		return false
+	}
+
+	return a.Collection.Equal(otherAny.Collection) && a.Var == otherAny.Var && a.Predicate.Equal(otherAny.Predicate)
+}
+
+// AllExpr represents a test that every element of a collection satisfies a
+// predicate; it is true for an empty collection. The predicate is evaluated
+// as for AnyExpr.
+type AllExpr struct {
+	// The collection whose elements are tested
+	Collection Expr
+	// The name of the variable bound to each element
+	Var string
+	// The predicate to evaluate for each element
+	Predicate Expr
+}
+
+func (a AllExpr) Eval(env map[string]interface{}) (interface{}, error) {
+	elements, err := evalCollection(env, a.Collection, "$all")
+	if err != nil {
+		return false, err
+	}
+
+	scope := childScope(env)
+	for _, element := range elements {
+		scope[a.Var] = element
+		ok, err := evalPredicate(scope, a.Predicate)
+		if err != nil {
+			return false, err
+		}
+		if !ok {
 			return false, nil
-		} else {
-			return nil, errors.New("mismatched types for $in()")
 		}
 	}
 
-	queryBool, err := coerceBool(queryVal)
-	if err == nil {
-		sliceBool, err := coerceBoolSlice(sliceVal)
-		if err == nil {
-			for _, u := range sliceBool {
-				if u == queryBool {
-					return true, nil
-				}
-			}
-			return false, nil
-		} else {
-			return nil, errors.New("mismatched types for $in()")
+	return true, nil
+}
+
+func (a AllExpr) Equal(other Expr) bool {
+	otherAll, ok := other.(AllExpr)
+	if !ok {
+		return false
+	}
+
+	return a.Collection.Equal(otherAll.Collection) && a.Var == otherAll.Var && a.Predicate.Equal(otherAll.Predicate)
+}
+
+// Evaluate the truthy-ness of a predicate.
+func evalPredicate(env map[string]interface{}, predicate Expr) (bool, error) {
+	r, err := predicate.Eval(env)
+	if err != nil {
+		return false, err
+	}
+	return truthy(r)
+}
+
+// ----------------------------------------------------------------------------
+// Aggregates
+// ----------------------------------------------------------------------------
+
+// LenExpr represents the length of a string, in characters, or the number of
+// elements in a collection.
+type LenExpr struct {
+	Expr Expr
+}
+
+func (l LenExpr) Eval(env map[string]interface{}) (interface{}, error) {
+	value, err := l.Expr.Eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	if str, err := coerceStr(value); err == nil {
+		return uint(utf8.RuneCountInString(str)), nil
+	}
+
+	elements, err := coerceCollection(value)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected value for $len(): %w", err)
+	}
+
+	return uint(len(elements)), nil
+}
+
+func (l LenExpr) Equal(other Expr) bool {
+	otherLen, ok := other.(LenExpr)
+	if !ok {
+		return false
+	}
+
+	return l.Expr.Equal(otherLen.Expr)
+}
+
+// CountExpr represents the number of elements of a collection that satisfy a
+// predicate. The predicate is evaluated as for AnyExpr.
+type CountExpr struct {
+	// The collection whose elements are counted
+	Collection Expr
+	// The name of the variable bound to each element
+	Var string
+	// The predicate to evaluate for each element
+	Predicate Expr
+}
+
+func (c CountExpr) Eval(env map[string]interface{}) (interface{}, error) {
+	elements, err := evalCollection(env, c.Collection, "$count")
+	if err != nil {
+		return nil, err
+	}
+
+	var count uint
+	scope := childScope(env)
+	for _, element := range elements {
+		scope[c.Var] = element
+		ok, err := evalPredicate(scope, c.Predicate)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			count++
 		}
 	}
 
-	return nil, fmt.Errorf("unexpected type for $in() query")
+	return count, nil
 }
 
-func (i InExpr) Equal(other Expr) bool {
-	otherIn, ok := other.(InExpr)
+func (c CountExpr) Equal(other Expr) bool {
+	otherCount, ok := other.(CountExpr)
 	if !ok {
 		return false
 	}
-	return i.Element.Equal(otherIn.Element) && i.Collection.Equal(otherIn.Collection)
+
+	return c.Collection.Equal(otherCount.Collection) && c.Var == otherCount.Var && c.Predicate.Equal(otherCount.Predicate)
+}
+
+// SumExpr represents the sum of a collection of numbers. The sum of an empty
+// collection is zero, and it is an error for the sum to overflow.
+type SumExpr struct {
+	Collection Expr
+}
+
+func (s SumExpr) Eval(env map[string]interface{}) (interface{}, error) {
+	elements, err := evalCollection(env, s.Collection, "$sum")
+	if err != nil {
+		return nil, err
+	}
+
+	sum := number{kind: uintNumber}
+	for _, element := range elements {
+		n, err := coerceNumber(element)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected element in $sum() collection: %w", err)
+		}
+		sum, err = addNumbers(sum, n)
+		if err != nil {
+			return nil, fmt.Errorf("%w in $sum()", err)
+		}
+	}
+
+	return sum.value(), nil
+}
+
+func (s SumExpr) Equal(other Expr) bool {
+	otherSum, ok := other.(SumExpr)
+	if !ok {
+		return false
+	}
+
+	return s.Collection.Equal(otherSum.Collection)
+}
+
+// MinExpr represents the least element of a non-empty collection of values
+// that admit an ordering; see compareOrdered.
+type MinExpr struct {
+	Collection Expr
+}
+
+func (m MinExpr) Eval(env map[string]interface{}) (interface{}, error) {
+	return evalExtremum(env, m.Collection, "$min", -1)
+}
+
+func (m MinExpr) Equal(other Expr) bool {
+	otherMin, ok := other.(MinExpr)
+	if !ok {
+		return false
+	}
+
+	return m.Collection.Equal(otherMin.Collection)
+}
+
+// MaxExpr represents the greatest element of a non-empty collection of values
+// that admit an ordering; see compareOrdered.
+type MaxExpr struct {
+	Collection Expr
+}
+
+func (m MaxExpr) Eval(env map[string]interface{}) (interface{}, error) {
+	return evalExtremum(env, m.Collection, "$max", 1)
+}
+
+func (m MaxExpr) Equal(other Expr) bool {
+	otherMax, ok := other.(MaxExpr)
+	if !ok {
+		return false
+	}
+
+	return m.Collection.Equal(otherMax.Collection)
+}
+
+// Evaluate the least (sign -1) or greatest (sign +1) element of a collection.
+func evalExtremum(env map[string]interface{}, collection Expr, op string, sign int) (interface{}, error) {
+	elements, err := evalCollection(env, collection, op)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(elements) == 0 {
+		return nil, fmt.Errorf("empty collection for %s()", op)
+	}
+
+	result := elements[0]
+	for _, element := range elements {
+		c, err := compareOrdered(element, result)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected element in %s() collection: %w", op, err)
+		}
+		if c == sign {
+			result = element
+		}
+	}
+
+	return result, nil
 }
 
 // // Determine if any element of the slice satisfies the predicate.
@@ -0,0 +1,139 @@
+package authz
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+)
+
+// A Glob is a compiled wildcard pattern over '/'-separated paths.
+//
+// Within a path segment, '*' matches any sequence of characters and '?'
+// matches exactly one character; neither matches '/'. A segment consisting
+// solely of '**' matches zero or more complete segments.
+type Glob struct {
+	pattern  string
+	segments []string
+}
+
+// Compile a glob pattern.
+func CompileGlob(pattern string) (Glob, error) {
+	var segments []string
+	for _, segment := range strings.Split(pattern, "/") {
+		if segment != "**" && strings.Contains(segment, "**") {
+			return Glob{}, errors.New("'**' must occupy an entire path segment")
+		}
+		// Consecutive '**' segments are equivalent to a single one
+		if segment == "**" && len(segments) > 0 && segments[len(segments)-1] == "**" {
+			continue
+		}
+		segments = append(segments, segment)
+	}
+
+	return Glob{pattern: pattern, segments: segments}, nil
+}
+
+// Compile a glob pattern, panicking if it is invalid.
+func MustCompileGlob(pattern string) Glob {
+	g, err := CompileGlob(pattern)
+	if err != nil {
+		panic(err)
+	}
+	return g
+}
+
+// Determine if a path matches the pattern.
+func (g Glob) Match(path string) bool {
+	return matchGlobSegments(g.segments, strings.Split(path, "/"))
+}
+
+// Get the source text of the pattern.
+func (g Glob) String() string {
+	return g.pattern
+}
+
+// Match a sequence of pattern segments against a sequence of path segments.
+//
+// This mirrors matchGlobSegment one level up, with '**' in place of '*': only
+// the most recent '**' is ever backtracked into, which bounds the work by
+// len(pattern)*len(parts) however many '**' segments the pattern contains.
+func matchGlobSegments(pattern []string, parts []string) bool {
+	// Position to resume from if the most recent '**' must absorb another segment
+	starPattern, starPart := -1, 0
+
+	p, s := 0, 0
+	for s < len(parts) {
+		if p < len(pattern) && pattern[p] == "**" {
+			starPattern, starPart = p, s
+			p++
+			continue
+		}
+
+		if p < len(pattern) && matchGlobSegment(pattern[p], parts[s]) {
+			p++
+			s++
+			continue
+		}
+
+		if starPattern < 0 {
+			return false
+		}
+
+		starPart++
+		p, s = starPattern+1, starPart
+	}
+
+	// Any trailing '**' matches the empty remainder
+	for p < len(pattern) && pattern[p] == "**" {
+		p++
+	}
+
+	return p == len(pattern)
+}
+
+// Match a single pattern segment containing '*' and '?' against a path segment.
+func matchGlobSegment(pattern string, part string) bool {
+	// Position to resume from if the most recent '*' must absorb another character
+	starPattern, starPart := -1, 0
+
+	p, s := 0, 0
+	for s < len(part) {
+		if p < len(pattern) && pattern[p] == '*' {
+			starPattern, starPart = p, s
+			p++
+			continue
+		}
+
+		if p < len(pattern) {
+			if pattern[p] == '?' {
+				_, n := utf8.DecodeRuneInString(part[s:])
+				p++
+				s += n
+				continue
+			}
+
+			pr, pn := utf8.DecodeRuneInString(pattern[p:])
+			sr, sn := utf8.DecodeRuneInString(part[s:])
+			if pr == sr {
+				p += pn
+				s += sn
+				continue
+			}
+		}
+
+		if starPattern < 0 {
+			return false
+		}
+
+		_, n := utf8.DecodeRuneInString(part[starPart:])
+		starPart += n
+		p, s = starPattern+1, starPart
+	}
+
+	// Any trailing '*' matches the empty remainder
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+
+	return p == len(pattern)
+}
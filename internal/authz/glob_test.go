@@ -0,0 +1,95 @@
+package authz
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// Glob patterns match paths with path-segment semantics.
+func TestGlobMatch(t *testing.T) {
+	data := []struct {
+		pattern string
+		input   string
+		want    bool
+	}{
+		{"documents/*/read", "documents/42/read", true},
+		{"documents/*/read", "documents/42/write", false},
+		{"documents/*/read", "documents/42/7/read", false},
+		{"documents/*/read", "documents//read", true},
+		{"bucket/**", "bucket", true},
+		{"bucket/**", "bucket/a", true},
+		{"bucket/**", "bucket/a/b/c", true},
+		{"bucket/**", "buckets/a", false},
+		{"**/read", "read", true},
+		{"**/read", "a/b/read", true},
+		{"a/**/**/z", "a/z", true},
+		{"a/**/z", "a/b/c/z", true},
+		{"a/**/z", "a/b/c/y", false},
+		{"a/**/b/**/c", "a/x/b/y/b/z/c", true},
+		{"a/**/b/**/c", "a/x/c/y/b", false},
+		{"**/b/*", "a/b/b/c", true},
+		{"file-?.txt", "file-1.txt", true},
+		{"file-?.txt", "file-10.txt", false},
+		{"file-?", "file-é", true},
+		{"*.txt", "notes.txt", true},
+		{"*.txt", "dir/notes.txt", false},
+		{"a*b*c", "aXXbYYc", true},
+		{"a*b*c", "aXXbYY", false},
+		{"*", "", true},
+		{"", "", true},
+		{"", "a", false},
+	}
+	for _, d := range data {
+		t.Run(fmt.Sprintf("%s~%s", d.pattern, d.input), func(t *testing.T) {
+			g, err := CompileGlob(d.pattern)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got := g.Match(d.input); got != d.want {
+				t.Fatalf("got %v, want %v", got, d.want)
+			}
+		})
+	}
+}
+
+// Many '**' segments against a long non-matching path complete promptly.
+func TestGlobMatchPathological(t *testing.T) {
+	pattern := strings.Repeat("**/a/", 20) + "b"
+	input := strings.Repeat("a/", 60) + "c"
+
+	g, err := CompileGlob(pattern)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if g.Match(input) {
+		t.Fatalf("got true, want false")
+	}
+}
+
+// Glob compilation rejects malformed patterns.
+func TestCompileGlob(t *testing.T) {
+	data := []struct {
+		pattern     string
+		expectError error
+	}{
+		{"bucket/**", nil},
+		{"bucket/**/*.txt", nil},
+		{"bucket/a**", errors.New("")},
+		{"**b/c", errors.New("")},
+	}
+	for _, d := range data {
+		t.Run(d.pattern, func(t *testing.T) {
+			_, err := CompileGlob(d.pattern)
+			if err != nil && d.expectError == nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err == nil && d.expectError != nil {
+				t.Fatalf("expected error: %v", d.expectError)
+			}
+		})
+	}
+}
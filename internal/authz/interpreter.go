@@ -3,12 +3,14 @@ package authz
 import "fmt"
 
 // The Interpreter is responsible for evaluating expressions.
-type Interpreter struct{}
+type Interpreter struct {
+	// The parser used to parse expressions prior to evaluation.
+	Parser ExprParser
+}
 
 // Evaluate an expression with the given parameters.
 func (i Interpreter) Eval(expr string, params map[string]interface{}) (interface{}, error) {
-	p := ExprParser{}
-	parsed, err := p.Parse(expr)
+	parsed, err := i.Parser.Parse(expr)
 	if err != nil {
 		return nil, fmt.Errorf("parse error: %w", err)
 	}
@@ -66,3 +68,33 @@ func (i Interpreter) Uint(expr string, params map[string]interface{}) (uint, err
 
 	return r, nil
 }
+
+// Evaluate a signed integer-valued expression with the given parameters.
+func (i Interpreter) Int(expr string, params map[string]interface{}) (int64, error) {
+	result, err := i.Eval(expr, params)
+	if err != nil {
+		return 0, err
+	}
+
+	r, err := coerceInt(result)
+	if err != nil {
+		return 0, fmt.Errorf("failed to coerce expression result to int: %w", err)
+	}
+
+	return r, nil
+}
+
+// Evaluate a float-valued expression with the given parameters.
+func (i Interpreter) Float(expr string, params map[string]interface{}) (float64, error) {
+	result, err := i.Eval(expr, params)
+	if err != nil {
+		return 0, err
+	}
+
+	r, err := coerceFloat(result)
+	if err != nil {
+		return 0, fmt.Errorf("failed to coerce expression result to float: %w", err)
+	}
+
+	return r, nil
+}
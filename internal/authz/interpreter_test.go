@@ -1,6 +1,10 @@
 package authz
 
-import "testing"
+import (
+	"errors"
+	"testing"
+	"time"
+)
 
 // Interpreter can interpret boolean-valued expressions.
 func TestBool(t *testing.T) {
@@ -24,6 +28,84 @@ func TestBool(t *testing.T) {
 		{"$eq(value, false)", map[string]interface{}{"value": false}, true, nil},
 		{"$in('foo', []str{'foo' , 'bar'})", nil, true, nil},
 		{"$in('baz', []str{'foo' , 'bar'})", nil, false, nil},
+		{"$gte(user.Clearance, 3)", map[string]interface{}{"user": struct{ Clearance uint8 }{3}}, true, nil},
+		{"$gte(user.Clearance, 3)", map[string]interface{}{"user": struct{ Clearance uint8 }{2}}, false, nil},
+		{"$lt(count, quota)", map[string]interface{}{"count": 9, "quota": uint(10)}, true, nil},
+		{"$lte('alpha', name)", map[string]interface{}{"name": "beta"}, true, nil},
+		{"$gt(1, 1)", nil, false, nil},
+		{"$ne(value, 'guest')", map[string]interface{}{"value": "admin"}, true, nil},
+		{"$ne(value, 7)", map[string]interface{}{"value": 7}, false, nil},
+		{"$startsWith(resource, 'org/42/')", map[string]interface{}{"resource": "org/42/project/7"}, true, nil},
+		{"$endsWith(user.Email, '@corp.com')", map[string]interface{}{"user": struct{ Email string }{"bob@evil.com"}}, false, nil},
+		{"$contains('org/42/project/7', '/project/')", nil, true, nil},
+		{"$matches(email, '@corp\\.com$')", map[string]interface{}{"email": "alice@corp.com"}, true, nil},
+		{"$matches(id, '^tenant-[0-9]+/')", map[string]interface{}{"id": "tenant-x/doc"}, false, nil},
+		{"$glob(permission, 'documents/*/read')", map[string]interface{}{"permission": "documents/7/read"}, true, nil},
+		{"$in(permission, []glob{'documents/*/read', 'bucket/**'})", map[string]interface{}{"permission": "bucket/x/y"}, true, nil},
+		{"$in(permission, []glob{'documents/*/read', 'bucket/**'})", map[string]interface{}{"permission": "documents/7/delete"}, false, nil},
+		{"$ipInCidr(addr, '10.0.0.0/8')", map[string]interface{}{"addr": "10.20.30.40"}, true, nil},
+		{"$ipInCidr(addr, '2001:db8::/32')", map[string]interface{}{"addr": "2001:db8::42"}, true, nil},
+		{"$ipInCidr('10.1.2.3', '::ffff:10.0.0.0/104')", nil, true, nil},
+		{"$ipInCidr('::ffff:10.1.2.3', '::ffff:10.0.0.0/104')", nil, true, nil},
+		{"$in(addr, []cidr{'10.0.0.0/8', '2001:db8::/32'})", map[string]interface{}{"addr": "172.16.0.1"}, false, nil},
+		{"$lt(account.Balance, -100)", map[string]interface{}{"account": struct{ Balance float64 }{-250.75}}, true, nil},
+		{"$gte(score, 0.5)", map[string]interface{}{"score": float32(0.75)}, true, nil},
+		{"$eq(offset, -3)", map[string]interface{}{"offset": int8(-3)}, true, nil},
+		{"$eq(id, 1_234_567_890_123)", map[string]interface{}{"id": uint64(1234567890123)}, true, nil},
+		{"$in(id, []uint{0x1_0000_0000, 17})", map[string]interface{}{"id": uint64(1 << 32)}, true, nil},
+		{"$eq(user.Org.Owner.Name, 'alice')", map[string]interface{}{"user": nestedUser}, true, nil},
+		{"$eq(user.Org.Meta.Region, 'us')", map[string]interface{}{"user": &nestedUser}, false, nil},
+		{"$eq(claims['groups'][0], 'admin')", map[string]interface{}{"claims": jsonClaims}, true, nil},
+		{"$in(claims.org['display name'], []str{'Acme', 'Initech'})", map[string]interface{}{"claims": jsonClaims}, true, nil},
+		{"$or($not($exists(department)), $eq(department, 'eng'))", nil, true, nil},
+		{"$or($not($exists(department)), $eq(department, 'eng'))", map[string]interface{}{"department": "sales"}, false, nil},
+		{"$eq($coalesce(claims['tier'], 'free'), 'free')", map[string]interface{}{"claims": map[string]string{}}, true, nil},
+		{"$eq(user?.Manager, null)", map[string]interface{}{"user": &testEmployee{ID: 1}}, true, nil},
+		{"$eq(role, 'admin')", map[string]interface{}{"role": testRole("admin")}, true, nil},
+		{"$in('admin', roles)", map[string]interface{}{"roles": testRoles{"viewer", "admin"}}, true, nil},
+		{"$in(role, []str{'admin', 'owner'})", map[string]interface{}{"role": testRole("viewer")}, false, nil},
+		{"$gte(level, 2)", map[string]interface{}{"level": testLevel(3)}, true, nil},
+		{"$in(2, levels)", map[string]interface{}{"levels": [3]testLevel{1, 2, 3}}, true, nil},
+		{"$in('admin', claims.roles)", map[string]interface{}{"claims": map[string]interface{}{"roles": []interface{}{"viewer", "admin"}}}, true, nil},
+		{"$in(action, allowed)", map[string]interface{}{"action": "read", "allowed": map[string]struct{}{"read": {}}}, true, nil},
+		{"$in(actor, []str{resource.Owner, 'admin@corp'})", map[string]interface{}{"actor": "bob@corp", "resource": map[string]string{"Owner": "bob@corp"}}, true, nil},
+		{"$in(actor, []str{resource.Owner, 'admin@corp'})", map[string]interface{}{"actor": "eve@corp", "resource": map[string]string{"Owner": "bob@corp"}}, false, nil},
+		{"$intersects(user.Roles, []str{'admin', 'owner'})", map[string]interface{}{"user": map[string][]string{"Roles": {"viewer", "owner"}}}, true, nil},
+		{"$subset([]str{'read', 'write'}, token.Scopes)", map[string]interface{}{"token": map[string][]string{"Scopes": {"read"}}}, false, nil},
+		{"$any(user.Memberships, m, $and($eq(m.Org, resource.Org), $eq(m.Role, 'admin')))", map[string]interface{}{"user": memberUser, "resource": map[string]string{"Org": "acme"}}, true, nil},
+		{"$all(user.Memberships, m, $in(m.Role, []str{'admin', 'owner'}))", map[string]interface{}{"user": memberUser}, false, nil},
+		{"$gte($count(request.Approvals, a, $ne(a, request.Author)), 2)", map[string]interface{}{"request": map[string]interface{}{"Author": "bob", "Approvals": []string{"bob", "alice"}}}, false, nil},
+		{"$gte($len(request.Approvals), 2)", map[string]interface{}{"request": map[string]interface{}{"Approvals": []string{"bob", "alice"}}}, true, nil},
+		{"$lt($sum(order.Prices), order.Limit)", map[string]interface{}{"order": map[string]interface{}{"Prices": []float64{9.99, 20}, "Limit": 30}}, true, nil},
+		{"$eq($max(scores), 9)", map[string]interface{}{"scores": []int{3, 9, 4}}, true, nil},
+		{"$lte($add(quota.Used, requested), quota.Limit)", map[string]interface{}{"quota": struct{ Used, Limit uint64 }{90, 100}, "requested": 10}, true, nil},
+		{"$lte($add(quota.Used, requested), quota.Limit)", map[string]interface{}{"quota": struct{ Used, Limit uint64 }{90, 100}, "requested": 11}, false, nil},
+		{"$eq($mod(id, 2), 0)", map[string]interface{}{"id": 42}, true, nil},
+		{"$if(resource.Public, $eq(action, 'read'), $in(user, resource.Members))", map[string]interface{}{"action": "read", "user": "eve", "resource": map[string]interface{}{"Public": true}}, true, nil},
+		{"$if(resource.Public, $eq(action, 'read'), $in(user, resource.Members))", map[string]interface{}{"action": "read", "user": "eve", "resource": map[string]interface{}{"Public": false, "Members": []string{"bob"}}}, false, nil},
+		{"$switch(resource.Kind, 'doc', $eq(action, 'read'), 'folder', $eq(action, 'list'), false)", map[string]interface{}{"action": "list", "resource": map[string]string{"Kind": "folder"}}, true, nil},
+		{"$switch(resource.Kind, 'doc', $eq(action, 'read'), 'folder', $eq(action, 'list'), false)", map[string]interface{}{"action": "list", "resource": map[string]string{"Kind": "image"}}, false, nil},
+		{"$let(org, user.Org.Owner.ID, $or($eq(org, 7), $eq(org, 8)))", map[string]interface{}{"user": nestedUser}, true, nil},
+		{"$let(user, 'shadowed', $eq(user, 'shadowed'))", map[string]interface{}{"user": nestedUser}, true, nil},
+		{"$let(x, 1, $exists($parent))", map[string]interface{}{"user": nestedUser}, false, nil},
+		{"$let(x, 1, $eq($parent, 'p'))", map[string]interface{}{"$parent": "p"}, true, nil},
+		{"$any(ids, id, $exists($parent))", map[string]interface{}{"ids": []int{1}}, false, nil},
+		{"$let(org, 'acme', $any(user.Memberships, m, $and($eq(m.Org, org), $let(org, 'other', $eq(m.Role, 'admin')))))", map[string]interface{}{"user": memberUser}, true, nil},
+		{"$eq($lower($trim(user.Email)), 'alice@corp.com')", map[string]interface{}{"user": map[string]string{"Email": " Alice@Corp.com "}}, true, nil},
+		{"$eqFold(group, 'Engineering')", map[string]interface{}{"group": "ENGINEERING"}, true, nil},
+		{"$eqFold('ß', 'SS')", nil, false, nil},
+		{"$in('admins', $split($lower(groups), ';'))", map[string]interface{}{"groups": "Users;Admins"}, true, nil},
+		{"$startsWith(resource, $concat('org/', user.Org, '/'))", map[string]interface{}{"resource": "org/acme/doc/1", "user": map[string]string{"Org": "acme"}}, true, nil},
+		{"$not(null)", nil, true, nil},
+		{"$and(true, user?.Manager?.ID)", map[string]interface{}{"user": &testEmployee{ID: 1}}, false, nil},
+		{"$and(true, user?.Manager?.ID)", map[string]interface{}{"user": &testEmployee{ID: 1, Manager: &testEmployee{ID: 2}}}, true, nil},
+		{"$eq(groups, null)", map[string]interface{}{"groups": []string(nil)}, false, nil},
+		{"$eq(groups, null)", map[string]interface{}{"groups": []string{}}, false, nil},
+		{"$exists(groups)", map[string]interface{}{"groups": []string(nil)}, true, nil},
+		{"$not(false)", nil, true, nil},
+		{"$not($in(user, []str{'mallory', 'eve'}))", map[string]interface{}{"user": "alice"}, true, nil},
+		{"$not($in(user, []str{'mallory', 'eve'}))", map[string]interface{}{"user": "eve"}, false, nil},
+		{"$and($not(blocked), $eq(role, 'admin'))", map[string]interface{}{"blocked": false, "role": "admin"}, true, nil},
 	}
 
 	for _, d := range data {
@@ -38,3 +120,108 @@ func TestBool(t *testing.T) {
 		}
 	}
 }
+
+// Interpreter evaluates time-bounded expressions against an injected clock.
+func TestBoolWithClock(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	data := []struct {
+		input     string
+		params    map[string]interface{}
+		want      bool
+		expectErr error
+	}{
+		{"$gte(issuedAt, $sub($now(), $duration('15m')))", map[string]interface{}{"issuedAt": now.Add(-10 * time.Minute)}, true, nil},
+		{"$gte(issuedAt, $sub($now(), $duration('15m')))", map[string]interface{}{"issuedAt": now.Add(-20 * time.Minute)}, false, nil},
+		{"$lt($now(), $time('2024-12-31T23:59:59Z'))", nil, true, nil},
+		{"$lt($now(), contract.End)", map[string]interface{}{"contract": struct{ End time.Time }{now.AddDate(0, 0, -1)}}, false, nil},
+		{"$lte($sub($now(), lastLogin), $duration('720h'))", map[string]interface{}{"lastLogin": now.AddDate(0, 0, -7)}, true, nil},
+		{"$eq($add(start, ttl), $now())", map[string]interface{}{"start": now.Add(-time.Hour), "ttl": time.Hour}, true, nil},
+	}
+
+	for _, d := range data {
+		i := Interpreter{Parser: ExprParser{Clock: func() time.Time { return now }}}
+
+		got, err := i.Bool(d.input, d.params)
+		if err != d.expectErr {
+			t.Errorf("unexpected error: got=%v, want=%v", err, d.expectErr)
+		}
+		if got != d.want {
+			t.Errorf("unexpected result: got=%v, want=%v", got, d.want)
+		}
+	}
+}
+
+// Interpreter can interpret signed integer and float-valued expressions.
+func TestIntFloat(t *testing.T) {
+	i := Interpreter{}
+
+	n, err := i.Int("-42", nil)
+	if err != nil || n != -42 {
+		t.Errorf("unexpected result: got=%v, err=%v", n, err)
+	}
+
+	n, err = i.Int("value", map[string]interface{}{"value": uint16(7)})
+	if err != nil || n != 7 {
+		t.Errorf("unexpected result: got=%v, err=%v", n, err)
+	}
+
+	if _, err := i.Int("1.5", nil); err == nil {
+		t.Errorf("expected error")
+	}
+
+	f, err := i.Float("-0.5", nil)
+	if err != nil || f != -0.5 {
+		t.Errorf("unexpected result: got=%v, err=%v", f, err)
+	}
+
+	f, err = i.Float("3", nil)
+	if err != nil || f != 3 {
+		t.Errorf("unexpected result: got=%v, err=%v", f, err)
+	}
+
+	if _, err := i.Float("'3'", nil); err == nil {
+		t.Errorf("expected error")
+	}
+}
+
+// Interpreter resolves struct fields by their tags.
+func TestBoolWithTags(t *testing.T) {
+	user := taggedUser{TenantID: "t-1", Email: "a@b.c", Password: "hunter2"}
+
+	i := Interpreter{}
+	got, err := i.Bool("$eq(user.tenant_id, 't-1')", map[string]interface{}{"user": user})
+	if err != nil || !got {
+		t.Errorf("unexpected result: got=%v, err=%v", got, err)
+	}
+
+	if _, err := i.Bool("$eq(user.Password, 'hunter2')", map[string]interface{}{"user": user}); err == nil {
+		t.Errorf("expected error for hidden field")
+	}
+
+	if _, err := i.Bool("$eq(user.email, 'a@b.c')", map[string]interface{}{"user": user}); err == nil {
+		t.Errorf("expected error for json tag without JSONTags")
+	}
+
+	i = Interpreter{Parser: ExprParser{JSONTags: true}}
+	got, err = i.Bool("$eq(user.email, 'a@b.c')", map[string]interface{}{"user": &user})
+	if err != nil || !got {
+		t.Errorf("unexpected result: got=%v, err=%v", got, err)
+	}
+}
+
+// Interpreter reports nil dereferences as errors rather than panicking.
+func TestNilDereference(t *testing.T) {
+	var user *testEmployee
+
+	i := Interpreter{}
+	_, err := i.Bool("$eq(user.ID, 1)", map[string]interface{}{"user": user})
+	if !errors.Is(err, ErrNilDereference) {
+		t.Errorf("unexpected error: got=%v, want=%v", err, ErrNilDereference)
+	}
+
+	got, err := i.Eval("user?.Manager?.ID", map[string]interface{}{"user": &testEmployee{ID: 1}})
+	if err != nil || got != nil {
+		t.Errorf("unexpected result: got=%v, err=%v", got, err)
+	}
+}
@@ -0,0 +1,227 @@
+package authz
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+)
+
+// The kind of a numeric value.
+type numberKind int
+
+const (
+	uintNumber numberKind = iota
+	intNumber
+	floatNumber
+)
+
+// A number is a value from the numeric tower: unsigned integers, signed
+// integers, and floating point values.
+type number struct {
+	kind numberKind
+	u    uint64
+	i    int64
+	f    float64
+}
+
+// Attempt to coerce a value to a number.
+func coerceNumber(v interface{}) (number, error) {
+	switch v := v.(type) {
+	case uint:
+		return number{kind: uintNumber, u: uint64(v)}, nil
+	case uint8:
+		return number{kind: uintNumber, u: uint64(v)}, nil
+	case uint16:
+		return number{kind: uintNumber, u: uint64(v)}, nil
+	case uint32:
+		return number{kind: uintNumber, u: uint64(v)}, nil
+	case uint64:
+		return number{kind: uintNumber, u: v}, nil
+	case int:
+		return number{kind: intNumber, i: int64(v)}, nil
+	case int8:
+		return number{kind: intNumber, i: int64(v)}, nil
+	case int16:
+		return number{kind: intNumber, i: int64(v)}, nil
+	case int32:
+		return number{kind: intNumber, i: int64(v)}, nil
+	case int64:
+		return number{kind: intNumber, i: v}, nil
+	case float32:
+		return number{kind: floatNumber, f: float64(v)}, nil
+	case float64:
+		return number{kind: floatNumber, f: v}, nil
+	}
+
+	// Values of named numeric types, e.g. `type Level int`
+	rv := underlyingValue(v)
+	switch rv.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return number{kind: uintNumber, u: rv.Uint()}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return number{kind: intNumber, i: rv.Int()}, nil
+	case reflect.Float32, reflect.Float64:
+		return number{kind: floatNumber, f: rv.Float()}, nil
+	default:
+		return number{}, fmt.Errorf("expected number, got %v", reflect.TypeOf(v))
+	}
+}
+
+// Get the Go value of a number: a uint, an int64, or a float64.
+func (n number) value() interface{} {
+	switch n.kind {
+	case uintNumber:
+		return uint(n.u)
+	case intNumber:
+		return n.i
+	default:
+		return n.f
+	}
+}
+
+// Determine if a number is zero.
+func (n number) isZero() bool {
+	switch n.kind {
+	case uintNumber:
+		return n.u == 0
+	case intNumber:
+		return n.i == 0
+	default:
+		return n.f == 0
+	}
+}
+
+// Get the exact value of a number as a big.Float.
+func (n number) bigFloat() *big.Float {
+	switch n.kind {
+	case uintNumber:
+		return new(big.Float).SetUint64(n.u)
+	case intNumber:
+		return new(big.Float).SetInt64(n.i)
+	default:
+		return new(big.Float).SetFloat64(n.f)
+	}
+}
+
+// Compare two numbers by their mathematical value, returning -1, 0, or +1.
+//
+// Integers are compared exactly regardless of signedness, so that e.g.
+// -1 < uint(0) and uint(math.MaxUint64) > int64(math.MaxInt64). When either
+// operand is a float, the comparison is still exact: the integer is not
+// rounded to the nearest float. NaN is unordered, and comparing it is an error.
+func compareNumbers(a, b number) (int, error) {
+	if a.kind == floatNumber || b.kind == floatNumber {
+		if (a.kind == floatNumber && math.IsNaN(a.f)) || (b.kind == floatNumber && math.IsNaN(b.f)) {
+			return 0, errors.New("NaN is not ordered")
+		}
+		return a.bigFloat().Cmp(b.bigFloat()), nil
+	}
+
+	switch {
+	case a.kind == uintNumber && b.kind == uintNumber:
+		return cmp.Compare(a.u, b.u), nil
+	case a.kind == intNumber && b.kind == intNumber:
+		return cmp.Compare(a.i, b.i), nil
+	case a.kind == uintNumber:
+		if b.i < 0 {
+			return 1, nil
+		}
+		return cmp.Compare(a.u, uint64(b.i)), nil
+	default:
+		if a.i < 0 {
+			return -1, nil
+		}
+		return cmp.Compare(uint64(a.i), b.u), nil
+	}
+}
+
+// Get the value of a number as a float64, rounding integers to the nearest
+// representable float.
+func (n number) float() float64 {
+	switch n.kind {
+	case uintNumber:
+		return float64(n.u)
+	case intNumber:
+		return float64(n.i)
+	default:
+		return n.f
+	}
+}
+
+// Get the exact value of an integer as a big.Int.
+func (n number) bigInt() *big.Int {
+	if n.kind == uintNumber {
+		return new(big.Int).SetUint64(n.u)
+	}
+	return big.NewInt(n.i)
+}
+
+// Add two numbers; see arithNumbers.
+func addNumbers(a, b number) (number, error) {
+	return arithNumbers(a, b, func(x, y float64) float64 { return x + y }, (*big.Int).Add)
+}
+
+// Subtract one number from another; see arithNumbers.
+func subNumbers(a, b number) (number, error) {
+	return arithNumbers(a, b, func(x, y float64) float64 { return x - y }, (*big.Int).Sub)
+}
+
+// Multiply two numbers; see arithNumbers.
+func mulNumbers(a, b number) (number, error) {
+	return arithNumbers(a, b, func(x, y float64) float64 { return x * y }, (*big.Int).Mul)
+}
+
+// Divide one number by another; see arithNumbers. Integer division truncates
+// toward zero, and it is an error to divide by zero.
+func divNumbers(a, b number) (number, error) {
+	if b.isZero() {
+		return number{}, errors.New("division by zero")
+	}
+	return arithNumbers(a, b, func(x, y float64) float64 { return x / y }, (*big.Int).Quo)
+}
+
+// Get the remainder of dividing one number by another; see arithNumbers. The
+// result has the sign of the dividend, as for Go's % operator and math.Mod,
+// and it is an error to divide by zero.
+func modNumbers(a, b number) (number, error) {
+	if b.isZero() {
+		return number{}, errors.New("division by zero")
+	}
+	return arithNumbers(a, b, math.Mod, (*big.Int).Rem)
+}
+
+// Perform an arithmetic operation on two numbers.
+//
+// If either operand is a float, the operation is performed on floats, and it
+// is an error for the result to overflow to an infinity. Otherwise the
+// operation is performed exactly on integers: the result of an operation on
+// two uints is a uint if it is non-negative, and any other result is an int
+// if it is in range, or else a uint. It is an error for the result to be out
+// of range of both.
+func arithNumbers(a, b number, floatOp func(x, y float64) float64, intOp func(z, x, y *big.Int) *big.Int) (number, error) {
+	if a.kind == floatNumber || b.kind == floatNumber {
+		x, y := a.float(), b.float()
+		r := floatOp(x, y)
+		if math.IsInf(r, 0) && !math.IsInf(x, 0) && !math.IsInf(y, 0) {
+			return number{}, errors.New("float overflow")
+		}
+		return number{kind: floatNumber, f: r}, nil
+	}
+
+	r := intOp(new(big.Int), a.bigInt(), b.bigInt())
+	switch {
+	case a.kind == uintNumber && b.kind == uintNumber && r.IsUint64():
+		return number{kind: uintNumber, u: r.Uint64()}, nil
+	case r.IsInt64():
+		return number{kind: intNumber, i: r.Int64()}, nil
+	case r.IsUint64():
+		return number{kind: uintNumber, u: r.Uint64()}, nil
+	case r.Sign() < 0:
+		return number{}, errors.New("integer underflow")
+	default:
+		return number{}, errors.New("integer overflow")
+	}
+}
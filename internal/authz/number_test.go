@@ -0,0 +1,160 @@
+package authz
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+)
+
+// Numbers of different kinds are compared by their mathematical value.
+func TestCompareNumbers(t *testing.T) {
+	data := []struct {
+		left        interface{}
+		right       interface{}
+		want        int
+		expectError error
+	}{
+		{uint(1), uint(2), -1, nil},
+		{int8(-1), uint8(0), -1, nil},
+		{uint64(math.MaxUint64), int64(math.MaxInt64), 1, nil},
+		{int64(math.MinInt64), int64(math.MinInt64), 0, nil},
+		{int(7), uint32(7), 0, nil},
+		{float32(0.5), float64(0.5), 0, nil},
+		{float64(1 << 63), uint64(1 << 63), 0, nil},
+		{float64(1 << 63), int64(math.MaxInt64), 1, nil},
+		{int64(-3), float64(-2.5), -1, nil},
+		{math.Inf(1), uint64(math.MaxUint64), 1, nil},
+		{math.NaN(), 1, 0, errors.New("")},
+		{1, math.NaN(), 0, errors.New("")},
+	}
+	for _, d := range data {
+		t.Run(fmt.Sprintf("%v<=>%v", d.left, d.right), func(t *testing.T) {
+			l, err := coerceNumber(d.left)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			r, err := coerceNumber(d.right)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			got, err := compareNumbers(l, r)
+			if err != nil {
+				if d.expectError == nil {
+					t.Fatalf("unexpected error: %v", err)
+				} else {
+					return
+				}
+			}
+
+			if d.expectError != nil {
+				if err == nil {
+					t.Fatalf("expected error: %v", d.expectError)
+				} else {
+					return
+				}
+			}
+
+			if got != d.want {
+				t.Fatalf("got %v, want %v", got, d.want)
+			}
+		})
+	}
+}
+
+// Coercion to number fails for non-numeric values.
+func TestCoerceNumberFail(t *testing.T) {
+	for _, v := range []interface{}{"1", true, nil, []int{1}} {
+		if _, err := coerceNumber(v); err == nil {
+			t.Fatalf("expected error for %v", v)
+		}
+	}
+}
+
+// Arithmetic on numbers is exact for integers and reports overflow.
+func TestArithNumbers(t *testing.T) {
+	data := []struct {
+		op          string
+		left        interface{}
+		right       interface{}
+		want        interface{}
+		expectError error
+	}{
+		{"add", uint(1), uint(2), uint(3), nil},
+		{"add", uint64(math.MaxUint64), uint(1), nil, errors.New("")},
+		{"add", -1, 2, int64(1), nil},
+		{"add", int64(math.MaxInt64), 1, uint(1 << 63), nil},
+		{"add", int64(math.MinInt64), -1, nil, errors.New("")},
+		{"add", uint64(math.MaxUint64), -1, uint(math.MaxUint64 - 1), nil},
+		{"add", uint(1), -3, int64(-2), nil},
+		{"add", 0.5, uint(1), 1.5, nil},
+		{"add", math.MaxFloat64, math.MaxFloat64, nil, errors.New("")},
+		{"add", math.Inf(1), 1.0, math.Inf(1), nil},
+		{"sub", uint(5), uint(3), uint(2), nil},
+		{"sub", uint(3), uint(5), int64(-2), nil},
+		{"sub", uint(0), uint64(math.MaxUint64), nil, errors.New("")},
+		{"sub", int64(math.MinInt64), 1, nil, errors.New("")},
+		{"sub", 1.5, 2, -0.5, nil},
+		{"mul", uint(1 << 32), uint(1 << 31), uint(1 << 63), nil},
+		{"mul", uint(1 << 32), uint(1 << 32), nil, errors.New("")},
+		{"mul", -3, uint(4), int64(-12), nil},
+		{"mul", int64(math.MinInt64), -1, uint(1 << 63), nil},
+		{"mul", math.MaxFloat64, 2, nil, errors.New("")},
+		{"div", uint(7), uint(2), uint(3), nil},
+		{"div", -7, 2, int64(-3), nil},
+		{"div", 7.0, 2, 3.5, nil},
+		{"div", uint(1), uint(0), nil, errors.New("")},
+		{"div", 1.0, 0.0, nil, errors.New("")},
+		{"div", math.MaxFloat64, 0.5, nil, errors.New("")},
+		{"mod", uint(7), uint(3), uint(1), nil},
+		{"mod", -7, 3, int64(-1), nil},
+		{"mod", 7, -3, int64(1), nil},
+		{"mod", 7.5, 2, 1.5, nil},
+		{"mod", 7, 0, nil, errors.New("")},
+		{"mod", 7.5, 0.0, nil, errors.New("")},
+	}
+	for _, d := range data {
+		t.Run(fmt.Sprintf("%s(%v, %v)", d.op, d.left, d.right), func(t *testing.T) {
+			l, err := coerceNumber(d.left)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			r, err := coerceNumber(d.right)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var got number
+			switch d.op {
+			case "add":
+				got, err = addNumbers(l, r)
+			case "sub":
+				got, err = subNumbers(l, r)
+			case "mul":
+				got, err = mulNumbers(l, r)
+			case "div":
+				got, err = divNumbers(l, r)
+			case "mod":
+				got, err = modNumbers(l, r)
+			default:
+				t.Fatalf("unknown op %s", d.op)
+			}
+			if err != nil {
+				if d.expectError == nil {
+					t.Fatalf("unexpected error: %v", err)
+				} else {
+					return
+				}
+			}
+
+			if d.expectError != nil {
+				t.Fatalf("expected error: %v", d.expectError)
+			}
+
+			if got.value() != d.want {
+				t.Fatalf("got %v (%T), want %v (%T)", got.value(), got.value(), d.want, d.want)
+			}
+		})
+	}
+}
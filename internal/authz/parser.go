@@ -3,13 +3,29 @@ package authz
 import (
 	"errors"
 	"fmt"
+	"math"
+	"net/netip"
+	"regexp"
 	"strconv"
 	"strings"
+	"time"
 	"unicode"
 )
 
+// The default maximum length of a regular expression pattern.
+const DefaultMaxPatternLength = 1024
+
 // The ExprParser is capable of parsing expressions from a string.
-type ExprParser struct{}
+type ExprParser struct {
+	// The maximum length of a regular expression pattern accepted by
+	// $matches(); if zero, DefaultMaxPatternLength is used.
+	MaxPatternLength int
+	// The clock consulted by $now(); if nil, time.Now is used.
+	Clock func() time.Time
+	// Whether field references may name struct fields by their `json` tags,
+	// for fields that have no `authz` tag; see GetField.
+	JSONTags bool
+}
 
 func (ep ExprParser) Parse(expr string) (Expr, error) {
 	if len(expr) == 0 {
@@ -26,146 +42,898 @@ func (ep ExprParser) Parse(expr string) (Expr, error) {
 		return nil, errors.New("unexpected token")
 	}
 
-	return parsed, nil
+	return parsed, nil
+}
+
+// Parse an expression.
+func (ep ExprParser) parseExpr(expr string) (Expr, int, error) {
+	token, err := ep.nextToken(expr)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	switch token {
+	case "$eq":
+		return ep.parseEqExpr(expr)
+	case "$ne":
+		return ep.parseNeExpr(expr)
+	case "$in":
+		return ep.parseInExpr(expr)
+	case "$subset":
+		return ep.parseSubsetExpr(expr)
+	case "$intersects":
+		return ep.parseIntersectsExpr(expr)
+	case "$disjoint":
+		return ep.parseDisjointExpr(expr)
+	case "$equalSets":
+		return ep.parseEqualSetsExpr(expr)
+	case "$lt":
+		return ep.parseLtExpr(expr)
+	case "$lte":
+		return ep.parseLteExpr(expr)
+	case "$gt":
+		return ep.parseGtExpr(expr)
+	case "$gte":
+		return ep.parseGteExpr(expr)
+	case "$startsWith":
+		return ep.parseStartsWithExpr(expr)
+	case "$endsWith":
+		return ep.parseEndsWithExpr(expr)
+	case "$contains":
+		return ep.parseContainsExpr(expr)
+	case "$matches":
+		return ep.parseMatchesExpr(expr)
+	case "$eqFold":
+		return ep.parseEqFoldExpr(expr)
+	case "$lower":
+		return ep.parseLowerExpr(expr)
+	case "$upper":
+		return ep.parseUpperExpr(expr)
+	case "$trim":
+		return ep.parseTrimExpr(expr)
+	case "$concat":
+		return ep.parseConcatExpr(expr)
+	case "$split":
+		return ep.parseSplitExpr(expr)
+	case "$replace":
+		return ep.parseReplaceExpr(expr)
+	case "$glob":
+		return ep.parseGlobExpr(expr)
+	case "$ipInCidr":
+		return ep.parseIPInCIDRExpr(expr)
+	case "$time":
+		return ep.parseTimeExpr(expr)
+	case "$duration":
+		return ep.parseDurationExpr(expr)
+	case "$now":
+		return ep.parseNowExpr(expr)
+	case "$add":
+		return ep.parseAddExpr(expr)
+	case "$sub":
+		return ep.parseSubExpr(expr)
+	case "$mul":
+		return ep.parseMulExpr(expr)
+	case "$div":
+		return ep.parseDivExpr(expr)
+	case "$mod":
+		return ep.parseModExpr(expr)
+	case "$any":
+		return ep.parseAnyExpr(expr)
+	case "$len":
+		return ep.parseLenExpr(expr)
+	case "$count":
+		return ep.parseCountExpr(expr)
+	case "$sum":
+		return ep.parseSumExpr(expr)
+	case "$min":
+		return ep.parseMinExpr(expr)
+	case "$max":
+		return ep.parseMaxExpr(expr)
+	case "$all":
+		return ep.parseAllExpr(expr)
+	case "$and":
+		return ep.parseAndExpr(expr)
+	case "$or":
+		return ep.parseOrExpr(expr)
+	case "$not":
+		return ep.parseNotExpr(expr)
+	case "$let":
+		return ep.parseLetExpr(expr)
+	case "$if":
+		return ep.parseIfExpr(expr)
+	case "$switch":
+		return ep.parseSwitchExpr(expr)
+	case "$exists":
+		return ep.parseExistsExpr(expr)
+	case "$coalesce":
+		return ep.parseCoalesceExpr(expr)
+	default:
+		return ep.parseNonOperator(expr)
+	}
+}
+
+// Get the next operator token from the expression.
+func (ep ExprParser) nextToken(expr string) (string, error) {
+	if len(expr) == 0 {
+		return "", errors.New("unexpected end of input")
+	}
+
+	for i, c := range expr {
+		if isTokenTerminator(c) {
+			return expr[:i], nil
+		}
+		if i == len(expr)-1 {
+			return expr, nil
+		}
+	}
+
+	return "", errors.New("expected ' ', ',', '(', or end of input")
+}
+
+// ----------------------------------------------------------------------------
+// Operator Expressions
+// ----------------------------------------------------------------------------
+
+// Parse an equality expression.
+func (ep ExprParser) parseEqExpr(expr string) (EqExpr, int, error) {
+	left, right, consumed, err := ep.parseBinaryOperator(expr, "$eq")
+	if err != nil {
+		return EqExpr{}, 0, err
+	}
+
+	return EqExpr{Left: left, Right: right}, consumed, nil
+}
+
+// Parse an inequality expression.
+func (ep ExprParser) parseNeExpr(expr string) (NeExpr, int, error) {
+	left, right, consumed, err := ep.parseBinaryOperator(expr, "$ne")
+	if err != nil {
+		return NeExpr{}, 0, err
+	}
+
+	return NeExpr{Left: left, Right: right}, consumed, nil
+}
+
+// Parse an $in expression.
+func (ep ExprParser) parseInExpr(expr string) (InExpr, int, error) {
+	query, collection, consumed, err := ep.parseBinaryOperator(expr, "$in")
+	if err != nil {
+		return InExpr{}, 0, err
+	}
+
+	return InExpr{Element: query, Collection: collection}, consumed, nil
+}
+
+// Parse a $subset expression.
+func (ep ExprParser) parseSubsetExpr(expr string) (SubsetExpr, int, error) {
+	left, right, consumed, err := ep.parseBinaryOperator(expr, "$subset")
+	if err != nil {
+		return SubsetExpr{}, 0, err
+	}
+
+	return SubsetExpr{Left: left, Right: right}, consumed, nil
+}
+
+// Parse an $intersects expression.
+func (ep ExprParser) parseIntersectsExpr(expr string) (IntersectsExpr, int, error) {
+	left, right, consumed, err := ep.parseBinaryOperator(expr, "$intersects")
+	if err != nil {
+		return IntersectsExpr{}, 0, err
+	}
+
+	return IntersectsExpr{Left: left, Right: right}, consumed, nil
+}
+
+// Parse a $disjoint expression.
+func (ep ExprParser) parseDisjointExpr(expr string) (DisjointExpr, int, error) {
+	left, right, consumed, err := ep.parseBinaryOperator(expr, "$disjoint")
+	if err != nil {
+		return DisjointExpr{}, 0, err
+	}
+
+	return DisjointExpr{Left: left, Right: right}, consumed, nil
+}
+
+// Parse an $equalSets expression.
+func (ep ExprParser) parseEqualSetsExpr(expr string) (EqualSetsExpr, int, error) {
+	left, right, consumed, err := ep.parseBinaryOperator(expr, "$equalSets")
+	if err != nil {
+		return EqualSetsExpr{}, 0, err
+	}
+
+	return EqualSetsExpr{Left: left, Right: right}, consumed, nil
+}
+
+// Parse a less-than expression.
+func (ep ExprParser) parseLtExpr(expr string) (LtExpr, int, error) {
+	left, right, consumed, err := ep.parseBinaryOperator(expr, "$lt")
+	if err != nil {
+		return LtExpr{}, 0, err
+	}
+
+	return LtExpr{Left: left, Right: right}, consumed, nil
+}
+
+// Parse a less-than-or-equal expression.
+func (ep ExprParser) parseLteExpr(expr string) (LteExpr, int, error) {
+	left, right, consumed, err := ep.parseBinaryOperator(expr, "$lte")
+	if err != nil {
+		return LteExpr{}, 0, err
+	}
+
+	return LteExpr{Left: left, Right: right}, consumed, nil
+}
+
+// Parse a greater-than expression.
+func (ep ExprParser) parseGtExpr(expr string) (GtExpr, int, error) {
+	left, right, consumed, err := ep.parseBinaryOperator(expr, "$gt")
+	if err != nil {
+		return GtExpr{}, 0, err
+	}
+
+	return GtExpr{Left: left, Right: right}, consumed, nil
+}
+
+// Parse a greater-than-or-equal expression.
+func (ep ExprParser) parseGteExpr(expr string) (GteExpr, int, error) {
+	left, right, consumed, err := ep.parseBinaryOperator(expr, "$gte")
+	if err != nil {
+		return GteExpr{}, 0, err
+	}
+
+	return GteExpr{Left: left, Right: right}, consumed, nil
+}
+
+// Parse a $startsWith expression.
+func (ep ExprParser) parseStartsWithExpr(expr string) (StartsWithExpr, int, error) {
+	value, prefix, consumed, err := ep.parseBinaryOperator(expr, "$startsWith")
+	if err != nil {
+		return StartsWithExpr{}, 0, err
+	}
+
+	return StartsWithExpr{Value: value, Prefix: prefix}, consumed, nil
+}
+
+// Parse an $endsWith expression.
+func (ep ExprParser) parseEndsWithExpr(expr string) (EndsWithExpr, int, error) {
+	value, suffix, consumed, err := ep.parseBinaryOperator(expr, "$endsWith")
+	if err != nil {
+		return EndsWithExpr{}, 0, err
+	}
+
+	return EndsWithExpr{Value: value, Suffix: suffix}, consumed, nil
+}
+
+// Parse a $contains expression.
+func (ep ExprParser) parseContainsExpr(expr string) (ContainsExpr, int, error) {
+	value, substring, consumed, err := ep.parseBinaryOperator(expr, "$contains")
+	if err != nil {
+		return ContainsExpr{}, 0, err
+	}
+
+	return ContainsExpr{Value: value, Substring: substring}, consumed, nil
+}
+
+// Parse a $matches expression. The pattern must be a string literal, and it
+// is compiled here so that an invalid pattern is reported as a parse error.
+func (ep ExprParser) parseMatchesExpr(expr string) (MatchesExpr, int, error) {
+	value, pattern, consumed, err := ep.parseBinaryOperator(expr, "$matches")
+	if err != nil {
+		return MatchesExpr{}, 0, err
+	}
+
+	patternStr, ok := pattern.(StrExpr)
+	if !ok {
+		return MatchesExpr{}, 0, errors.New("expected string literal pattern for $matches()")
+	}
+
+	maxLength := ep.MaxPatternLength
+	if maxLength == 0 {
+		maxLength = DefaultMaxPatternLength
+	}
+	if len(patternStr.Value) > maxLength {
+		return MatchesExpr{}, 0, fmt.Errorf("pattern for $matches() exceeds maximum length of %d", maxLength)
+	}
+
+	re, err := regexp.Compile(patternStr.Value)
+	if err != nil {
+		return MatchesExpr{}, 0, fmt.Errorf("invalid pattern for $matches(): %w", err)
+	}
+
+	return MatchesExpr{Value: value, Pattern: re}, consumed, nil
+}
+
+// Parse a $glob expression. The pattern must be a string literal.
+func (ep ExprParser) parseGlobExpr(expr string) (GlobExpr, int, error) {
+	value, pattern, consumed, err := ep.parseBinaryOperator(expr, "$glob")
+	if err != nil {
+		return GlobExpr{}, 0, err
+	}
+
+	patternStr, ok := pattern.(StrExpr)
+	if !ok {
+		return GlobExpr{}, 0, errors.New("expected string literal pattern for $glob()")
+	}
+
+	g, err := CompileGlob(patternStr.Value)
+	if err != nil {
+		return GlobExpr{}, 0, fmt.Errorf("invalid pattern for $glob(): %w", err)
+	}
+
+	return GlobExpr{Value: value, Pattern: g}, consumed, nil
+}
+
+// Parse an $ipInCidr expression. The network must be a string literal.
+func (ep ExprParser) parseIPInCIDRExpr(expr string) (IPInCIDRExpr, int, error) {
+	ip, network, consumed, err := ep.parseBinaryOperator(expr, "$ipInCidr")
+	if err != nil {
+		return IPInCIDRExpr{}, 0, err
+	}
+
+	networkStr, ok := network.(StrExpr)
+	if !ok {
+		return IPInCIDRExpr{}, 0, errors.New("expected string literal network for $ipInCidr()")
+	}
+
+	prefix, err := parseCIDR(networkStr.Value)
+	if err != nil {
+		return IPInCIDRExpr{}, 0, err
+	}
+
+	return IPInCIDRExpr{IP: ip, Prefix: prefix}, consumed, nil
+}
+
+// Parse an $eqFold expression.
+func (ep ExprParser) parseEqFoldExpr(expr string) (EqFoldExpr, int, error) {
+	left, right, consumed, err := ep.parseBinaryOperator(expr, "$eqFold")
+	if err != nil {
+		return EqFoldExpr{}, 0, err
+	}
+
+	return EqFoldExpr{Left: left, Right: right}, consumed, nil
+}
+
+// Parse a $lower expression.
+func (ep ExprParser) parseLowerExpr(expr string) (LowerExpr, int, error) {
+	arg, consumed, err := ep.parseUnaryOperator(expr, "$lower")
+	if err != nil {
+		return LowerExpr{}, 0, err
+	}
+
+	return LowerExpr{Expr: arg}, consumed, nil
+}
+
+// Parse an $upper expression.
+func (ep ExprParser) parseUpperExpr(expr string) (UpperExpr, int, error) {
+	arg, consumed, err := ep.parseUnaryOperator(expr, "$upper")
+	if err != nil {
+		return UpperExpr{}, 0, err
+	}
+
+	return UpperExpr{Expr: arg}, consumed, nil
+}
+
+// Parse a $trim expression.
+func (ep ExprParser) parseTrimExpr(expr string) (TrimExpr, int, error) {
+	arg, consumed, err := ep.parseUnaryOperator(expr, "$trim")
+	if err != nil {
+		return TrimExpr{}, 0, err
+	}
+
+	return TrimExpr{Expr: arg}, consumed, nil
+}
+
+// Parse a $concat expression.
+func (ep ExprParser) parseConcatExpr(expr string) (ConcatExpr, int, error) {
+	ok, consumed := expectPrefix(expr, "$concat(")
+	if !ok {
+		return ConcatExpr{}, 0, errors.New("expected '$concat('")
+	}
+
+	exprs, n, err := ep.parseExpressionSequence(expr[consumed:], ')', func(rest string) (Expr, int, error) {
+		return ep.parseExpr(rest)
+	})
+	if err != nil {
+		return ConcatExpr{}, 0, err
+	}
+	consumed += n
+
+	if len(exprs) == 0 {
+		return ConcatExpr{}, 0, errors.New("expected at least one argument for $concat()")
+	}
+
+	return ConcatExpr{Exprs: exprs}, consumed, nil
+}
+
+// Parse a $split expression.
+func (ep ExprParser) parseSplitExpr(expr string) (SplitExpr, int, error) {
+	value, sep, consumed, err := ep.parseBinaryOperator(expr, "$split")
+	if err != nil {
+		return SplitExpr{}, 0, err
+	}
+
+	return SplitExpr{Value: value, Separator: sep}, consumed, nil
+}
+
+// Parse a $replace expression.
+func (ep ExprParser) parseReplaceExpr(expr string) (ReplaceExpr, int, error) {
+	ok, consumed := expectPrefix(expr, "$replace(")
+	if !ok {
+		return ReplaceExpr{}, 0, errors.New("expected '$replace('")
+	}
+
+	args, n, err := ep.parseExpressionSequence(expr[consumed:], ')', func(rest string) (Expr, int, error) {
+		return ep.parseExpr(rest)
+	})
+	if err != nil {
+		return ReplaceExpr{}, 0, err
+	}
+	consumed += n
+
+	if len(args) != 3 {
+		return ReplaceExpr{}, 0, errors.New("expected string, old and new arguments for $replace()")
+	}
+
+	return ReplaceExpr{Value: args[0], Old: args[1], New: args[2]}, consumed, nil
+}
+
+// Parse a timestamp literal of the form `$time('2006-01-02T15:04:05Z')`.
+func (ep ExprParser) parseTimeExpr(expr string) (TimeExpr, int, error) {
+	value, consumed, err := ep.parseUnaryStrOperator(expr, "$time")
+	if err != nil {
+		return TimeExpr{}, 0, err
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return TimeExpr{}, 0, fmt.Errorf("invalid RFC 3339 timestamp: %w", err)
+	}
+
+	return TimeExpr{Value: t}, consumed, nil
+}
+
+// Parse a duration literal of the form `$duration('15m')`.
+func (ep ExprParser) parseDurationExpr(expr string) (DurationExpr, int, error) {
+	value, consumed, err := ep.parseUnaryStrOperator(expr, "$duration")
+	if err != nil {
+		return DurationExpr{}, 0, err
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return DurationExpr{}, 0, fmt.Errorf("invalid duration: %w", err)
+	}
+
+	return DurationExpr{Value: d}, consumed, nil
+}
+
+// Parse a $now() expression.
+func (ep ExprParser) parseNowExpr(expr string) (NowExpr, int, error) {
+	ok, consumed := expectPrefix(expr, "$now()")
+	if !ok {
+		return NowExpr{}, 0, errors.New("expected '$now()'")
+	}
+
+	return NowExpr{Clock: ep.Clock}, consumed, nil
+}
+
+// Parse an $add expression.
+func (ep ExprParser) parseAddExpr(expr string) (AddExpr, int, error) {
+	left, right, consumed, err := ep.parseBinaryOperator(expr, "$add")
+	if err != nil {
+		return AddExpr{}, 0, err
+	}
+
+	return AddExpr{Left: left, Right: right}, consumed, nil
+}
+
+// Parse a $sub expression.
+func (ep ExprParser) parseSubExpr(expr string) (SubExpr, int, error) {
+	left, right, consumed, err := ep.parseBinaryOperator(expr, "$sub")
+	if err != nil {
+		return SubExpr{}, 0, err
+	}
+
+	return SubExpr{Left: left, Right: right}, consumed, nil
+}
+
+// Parse a $mul expression.
+func (ep ExprParser) parseMulExpr(expr string) (MulExpr, int, error) {
+	left, right, consumed, err := ep.parseBinaryOperator(expr, "$mul")
+	if err != nil {
+		return MulExpr{}, 0, err
+	}
+
+	return MulExpr{Left: left, Right: right}, consumed, nil
+}
+
+// Parse a $div expression.
+func (ep ExprParser) parseDivExpr(expr string) (DivExpr, int, error) {
+	left, right, consumed, err := ep.parseBinaryOperator(expr, "$div")
+	if err != nil {
+		return DivExpr{}, 0, err
+	}
+
+	return DivExpr{Left: left, Right: right}, consumed, nil
+}
+
+// Parse a $mod expression.
+func (ep ExprParser) parseModExpr(expr string) (ModExpr, int, error) {
+	left, right, consumed, err := ep.parseBinaryOperator(expr, "$mod")
+	if err != nil {
+		return ModExpr{}, 0, err
+	}
+
+	return ModExpr{Left: left, Right: right}, consumed, nil
+}
+
+// Parse an $any expression.
+func (ep ExprParser) parseAnyExpr(expr string) (AnyExpr, int, error) {
+	collection, name, predicate, consumed, err := ep.parseQuantifier(expr, "$any")
+	if err != nil {
+		return AnyExpr{}, 0, err
+	}
+
+	return AnyExpr{Collection: collection, Var: name, Predicate: predicate}, consumed, nil
+}
+
+// Parse an $all expression.
+func (ep ExprParser) parseAllExpr(expr string) (AllExpr, int, error) {
+	collection, name, predicate, consumed, err := ep.parseQuantifier(expr, "$all")
+	if err != nil {
+		return AllExpr{}, 0, err
+	}
+
+	return AllExpr{Collection: collection, Var: name, Predicate: predicate}, consumed, nil
+}
+
+// Parse a $len expression.
+func (ep ExprParser) parseLenExpr(expr string) (LenExpr, int, error) {
+	arg, consumed, err := ep.parseUnaryOperator(expr, "$len")
+	if err != nil {
+		return LenExpr{}, 0, err
+	}
+
+	return LenExpr{Expr: arg}, consumed, nil
+}
+
+// Parse a $count expression.
+func (ep ExprParser) parseCountExpr(expr string) (CountExpr, int, error) {
+	collection, name, predicate, consumed, err := ep.parseQuantifier(expr, "$count")
+	if err != nil {
+		return CountExpr{}, 0, err
+	}
+
+	return CountExpr{Collection: collection, Var: name, Predicate: predicate}, consumed, nil
+}
+
+// Parse a $sum expression.
+func (ep ExprParser) parseSumExpr(expr string) (SumExpr, int, error) {
+	collection, consumed, err := ep.parseUnaryOperator(expr, "$sum")
+	if err != nil {
+		return SumExpr{}, 0, err
+	}
+
+	return SumExpr{Collection: collection}, consumed, nil
+}
+
+// Parse a $min expression.
+func (ep ExprParser) parseMinExpr(expr string) (MinExpr, int, error) {
+	collection, consumed, err := ep.parseUnaryOperator(expr, "$min")
+	if err != nil {
+		return MinExpr{}, 0, err
+	}
+
+	return MinExpr{Collection: collection}, consumed, nil
+}
+
+// Parse a $max expression.
+func (ep ExprParser) parseMaxExpr(expr string) (MaxExpr, int, error) {
+	collection, consumed, err := ep.parseUnaryOperator(expr, "$max")
+	if err != nil {
+		return MaxExpr{}, 0, err
+	}
+
+	return MaxExpr{Collection: collection}, consumed, nil
+}
+
+// Parse a quantifier over a collection, e.g. `$any(collection, x, predicate)`,
+// returning the collection, the name of the bound variable, and the predicate.
+func (ep ExprParser) parseQuantifier(expr string, name string) (Expr, string, Expr, int, error) {
+	ok, consumed := expectPrefix(expr, name+"(")
+	if !ok {
+		return nil, "", nil, 0, fmt.Errorf("expected '%s('", name)
+	}
+
+	args, n, err := ep.parseExpressionSequence(expr[consumed:], ')', func(rest string) (Expr, int, error) {
+		return ep.parseExpr(rest)
+	})
+	if err != nil {
+		return nil, "", nil, 0, err
+	}
+	consumed += n
+
+	if len(args) != 3 {
+		return nil, "", nil, 0, fmt.Errorf("expected collection, variable and predicate for %s()", name)
+	}
+
+	variable, err := bindingName(args[1], name)
+	if err != nil {
+		return nil, "", nil, 0, err
+	}
+
+	return args[0], variable, args[2], consumed, nil
+}
+
+// Get the name of a variable to be bound by the given operator, which must be
+// given as a plain variable reference. The literal names 'true', 'false' and
+// 'null', and names beginning with '$', are reserved and cannot be bound.
+func bindingName(e Expr, op string) (string, error) {
+	switch e := e.(type) {
+	case TrueExpr:
+		return "", fmt.Errorf("cannot bind reserved name 'true' in %s()", op)
+	case FalseExpr:
+		return "", fmt.Errorf("cannot bind reserved name 'false' in %s()", op)
+	case NullExpr:
+		return "", fmt.Errorf("cannot bind reserved name 'null' in %s()", op)
+	case VariableRefExpr:
+		if strings.HasPrefix(e.Name, "$") {
+			return "", fmt.Errorf("cannot bind reserved name '%s' in %s()", e.Name, op)
+		}
+		return e.Name, nil
+	default:
+		return "", fmt.Errorf("expected variable name for %s()", op)
+	}
+}
+
+// Parse an AND expression.
+func (ep ExprParser) parseAndExpr(expr string) (AndExpr, int, error) {
+	ok, consumed := expectPrefix(expr, "$and(")
+	if !ok {
+		return AndExpr{}, 0, errors.New("expected '$and('")
+	}
+
+	exprs, n, err := ep.parseExpressionSequence(expr[consumed:], ')', func(rest string) (Expr, int, error) {
+		return ep.parseExpr(rest)
+	})
+	if err != nil {
+		return AndExpr{}, 0, err
+	}
+	consumed += n
+
+	return AndExpr{Exprs: exprs}, consumed, nil
+}
+
+// Parse an OR expression.
+func (ep ExprParser) parseOrExpr(expr string) (OrExpr, int, error) {
+	ok, consumed := expectPrefix(expr, "$or(")
+	if !ok {
+		return OrExpr{}, 0, errors.New("expected '$or('")
+	}
+
+	exprs, n, err := ep.parseExpressionSequence(expr[consumed:], ')', func(rest string) (Expr, int, error) {
+		return ep.parseExpr(rest)
+	})
+	if err != nil {
+		return OrExpr{}, 0, err
+	}
+	consumed += n
+
+	return OrExpr{Exprs: exprs}, consumed, nil
+}
+
+// Parse a NOT expression.
+func (ep ExprParser) parseNotExpr(expr string) (NotExpr, int, error) {
+	inner, consumed, err := ep.parseUnaryOperator(expr, "$not")
+	if err != nil {
+		return NotExpr{}, 0, err
+	}
+
+	return NotExpr{Expr: inner}, consumed, nil
+}
+
+// Parse a $let expression.
+func (ep ExprParser) parseLetExpr(expr string) (LetExpr, int, error) {
+	ok, consumed := expectPrefix(expr, "$let(")
+	if !ok {
+		return LetExpr{}, 0, errors.New("expected '$let('")
+	}
+
+	args, n, err := ep.parseExpressionSequence(expr[consumed:], ')', func(rest string) (Expr, int, error) {
+		return ep.parseExpr(rest)
+	})
+	if err != nil {
+		return LetExpr{}, 0, err
+	}
+	consumed += n
+
+	if len(args) != 3 {
+		return LetExpr{}, 0, errors.New("expected variable, value and body for $let()")
+	}
+
+	name, err := bindingName(args[0], "$let")
+	if err != nil {
+		return LetExpr{}, 0, err
+	}
+
+	return LetExpr{Name: name, Value: args[1], Body: args[2]}, consumed, nil
+}
+
+// Parse an $if expression.
+func (ep ExprParser) parseIfExpr(expr string) (IfExpr, int, error) {
+	ok, consumed := expectPrefix(expr, "$if(")
+	if !ok {
+		return IfExpr{}, 0, errors.New("expected '$if('")
+	}
+
+	args, n, err := ep.parseExpressionSequence(expr[consumed:], ')', func(rest string) (Expr, int, error) {
+		return ep.parseExpr(rest)
+	})
+	if err != nil {
+		return IfExpr{}, 0, err
+	}
+	consumed += n
+
+	if len(args) != 3 {
+		return IfExpr{}, 0, errors.New("expected condition, then and else expressions for $if()")
+	}
+
+	return IfExpr{Cond: args[0], Then: args[1], Else: args[2]}, consumed, nil
+}
+
+// Parse a $switch expression, e.g. `$switch(subject, value, result, ..., default)`.
+// The subject is followed by pairs of case values and results, and optionally
+// by a default result.
+func (ep ExprParser) parseSwitchExpr(expr string) (SwitchExpr, int, error) {
+	ok, consumed := expectPrefix(expr, "$switch(")
+	if !ok {
+		return SwitchExpr{}, 0, errors.New("expected '$switch('")
+	}
+
+	args, n, err := ep.parseExpressionSequence(expr[consumed:], ')', func(rest string) (Expr, int, error) {
+		return ep.parseExpr(rest)
+	})
+	if err != nil {
+		return SwitchExpr{}, 0, err
+	}
+	consumed += n
+
+	if len(args) < 2 {
+		return SwitchExpr{}, 0, errors.New("expected subject and at least one case or default for $switch()")
+	}
+
+	result := SwitchExpr{Subject: args[0], Cases: make([]SwitchCase, 0)}
+	rest := args[1:]
+	for len(rest) >= 2 {
+		result.Cases = append(result.Cases, SwitchCase{Value: rest[0], Result: rest[1]})
+		rest = rest[2:]
+	}
+	if len(rest) == 1 {
+		result.Default = rest[0]
+	}
+
+	return result, consumed, nil
 }
 
-// Parse an expression.
-func (ep ExprParser) parseExpr(expr string) (Expr, int, error) {
-	token, err := ep.nextToken(expr)
+// Parse an EXISTS expression.
+func (ep ExprParser) parseExistsExpr(expr string) (ExistsExpr, int, error) {
+	ref, consumed, err := ep.parseUnaryOperator(expr, "$exists")
 	if err != nil {
-		return nil, 0, err
+		return ExistsExpr{}, 0, err
 	}
 
-	switch token {
-	case "$eq":
-		return ep.parseEqExpr(expr)
-	case "$in":
-		return ep.parseInExpr(expr)
-	case "$and":
-		return ep.parseAndExpr(expr)
-	case "$or":
-		return ep.parseOrExpr(expr)
+	switch ref.(type) {
+	case VariableRefExpr, StructFieldRefExpr:
 	default:
-		return ep.parseNonOperator(expr)
+		return ExistsExpr{}, 0, errors.New("expected variable or field reference for $exists()")
 	}
+
+	return ExistsExpr{Ref: ref}, consumed, nil
 }
 
-// Get the next operator token from the expression.
-func (ep ExprParser) nextToken(expr string) (string, error) {
-	if len(expr) == 0 {
-		return "", errors.New("unexpected end of input")
+// Parse a COALESCE expression.
+func (ep ExprParser) parseCoalesceExpr(expr string) (CoalesceExpr, int, error) {
+	ok, consumed := expectPrefix(expr, "$coalesce(")
+	if !ok {
+		return CoalesceExpr{}, 0, errors.New("expected '$coalesce('")
 	}
 
-	for i, c := range expr {
-		if isTokenTerminator(c) {
-			return expr[:i], nil
-		}
-		if i == len(expr)-1 {
-			return expr, nil
-		}
+	exprs, n, err := ep.parseExpressionSequence(expr[consumed:], ')', func(rest string) (Expr, int, error) {
+		return ep.parseExpr(rest)
+	})
+	if err != nil {
+		return CoalesceExpr{}, 0, err
 	}
+	consumed += n
 
-	return "", errors.New("expected ' ', ',', '(', or end of input")
-}
-
-// ----------------------------------------------------------------------------
-// Operator Expressions
-// ----------------------------------------------------------------------------
+	if len(exprs) == 0 {
+		return CoalesceExpr{}, 0, errors.New("expected at least one argument for $coalesce()")
+	}
 
-// Parse an equality expression.
-func (ep ExprParser) parseEqExpr(expr string) (EqExpr, int, error) {
-	precondition(len(expr) > len("$eq"))
+	return CoalesceExpr{Exprs: exprs}, consumed, nil
+}
 
-	ok, consumed := expectPrefix(expr, "$eq(")
+// Parse an operator that accepts exactly one argument, e.g. `$len(a)`.
+func (ep ExprParser) parseUnaryOperator(expr string, name string) (Expr, int, error) {
+	ok, consumed := expectPrefix(expr, name+"(")
 	if !ok {
-		return EqExpr{}, 0, errors.New("expected '$eq('")
+		return nil, 0, fmt.Errorf("expected '%s('", name)
 	}
 
-	left, right, n, err := ep.parseExpressionPair(expr[consumed:])
+	arg, n, err := ep.parseExpr(expr[consumed:])
 	if err != nil {
-		return EqExpr{}, 0, err
+		return nil, 0, err
 	}
 	consumed += n
 
 	if len(expr[consumed:]) == 0 {
-		return EqExpr{}, 0, errors.New("unexpected end of input")
+		return nil, 0, errors.New("unexpected end of input")
 	}
 
 	// Consume the closing parenthesis
 	if expr[consumed] != ')' {
-		return EqExpr{}, 0, errors.New("expected ')'")
+		return nil, 0, errors.New("expected ')'")
 	}
 	consumed++
 
-	return EqExpr{Left: left, Right: right}, consumed, nil
+	return arg, consumed, nil
 }
 
-// Parse an $in expression.
-func (ep ExprParser) parseInExpr(expr string) (InExpr, int, error) {
-	precondition(len(expr) > len("$in"))
-
-	ok, consumed := expectPrefix(expr, "$in(")
+// Parse an operator that accepts exactly two arguments, e.g. `$eq(a, b)`.
+func (ep ExprParser) parseBinaryOperator(expr string, name string) (Expr, Expr, int, error) {
+	ok, consumed := expectPrefix(expr, name+"(")
 	if !ok {
-		return InExpr{}, 0, errors.New("expected '$in('")
+		return nil, nil, 0, fmt.Errorf("expected '%s('", name)
 	}
 
-	query, collection, n, err := ep.parseExpressionPair(expr[consumed:])
+	left, right, n, err := ep.parseExpressionPair(expr[consumed:])
 	if err != nil {
-		return InExpr{}, 0, err
+		return nil, nil, 0, err
 	}
 	consumed += n
 
 	if len(expr[consumed:]) == 0 {
-		return InExpr{}, 0, errors.New("unexpected end of input")
+		return nil, nil, 0, errors.New("unexpected end of input")
 	}
 
 	// Consume the closing parenthesis
 	if expr[consumed] != ')' {
-		return InExpr{}, 0, errors.New("expected ')'")
+		return nil, nil, 0, errors.New("expected ')'")
 	}
 	consumed++
 
-	return InExpr{Element: query, Collection: collection}, consumed, nil
+	return left, right, consumed, nil
 }
 
-// Parse an AND expression.
-func (ep ExprParser) parseAndExpr(expr string) (AndExpr, int, error) {
-	precondition(len(expr) > len("$and"))
-
-	ok, consumed := expectPrefix(expr, "$and(")
+// Parse an operator that accepts a single string literal argument, e.g.
+// `$time('...')`, returning the value of the literal.
+func (ep ExprParser) parseUnaryStrOperator(expr string, name string) (string, int, error) {
+	ok, consumed := expectPrefix(expr, name+"(")
 	if !ok {
-		return AndExpr{}, 0, errors.New("expected '$and('")
+		return "", 0, fmt.Errorf("expected '%s('", name)
 	}
 
-	exprs, n, err := ep.parseExpressionSequence(expr[consumed:], ')', func(token string) (Expr, int, error) {
-		return ep.parseExpr(token)
-	})
+	if consumed >= len(expr) || expr[consumed] != '\'' {
+		return "", 0, fmt.Errorf("expected string literal for %s()", name)
+	}
+
+	str, n, err := ep.parseStrExpr(expr[consumed:])
 	if err != nil {
-		return AndExpr{}, 0, err
+		return "", 0, err
 	}
 	consumed += n
 
-	return AndExpr{Exprs: exprs}, consumed, nil
-}
-
-// Parse an OR expression.
-func (ep ExprParser) parseOrExpr(expr string) (OrExpr, int, error) {
-	precondition(len(expr) > len("$or"))
-
-	ok, consumed := expectPrefix(expr, "$or(")
-	if !ok {
-		return OrExpr{}, 0, errors.New("expected '$or('")
+	if len(expr[consumed:]) == 0 {
+		return "", 0, errors.New("unexpected end of input")
 	}
 
-	exprs, n, err := ep.parseExpressionSequence(expr[consumed:], ')', func(token string) (Expr, int, error) {
-		return ep.parseExpr(token)
-	})
-	if err != nil {
-		return OrExpr{}, 0, err
+	// Consume the closing parenthesis
+	if expr[consumed] != ')' {
+		return "", 0, errors.New("expected ')'")
 	}
-	consumed += n
+	consumed++
 
-	return OrExpr{Exprs: exprs}, consumed, nil
+	return str.Value, consumed, nil
 }
 
 // Expect the specified prefix.
@@ -193,12 +961,15 @@ func (ep ExprParser) parseNonOperator(expr string) (Expr, int, error) {
 	} else if len(expr) >= len("false") && expr[:len("false")] == "false" {
 		// 'false' literal
 		return ep.parseFalseExpr(expr)
+	} else if scanIdentifier(expr) == "null" {
+		// 'null' literal
+		return ep.parseNullExpr(expr)
 	} else if expr[0] == '\'' {
 		// String literal
 		return ep.parseStrExpr(expr)
-	} else if unicode.IsDigit(rune(expr[0])) {
-		// Integer literal
-		return ep.parseUintExpr(expr)
+	} else if unicode.IsDigit(rune(expr[0])) || expr[0] == '-' {
+		// Numeric literal
+		return ep.parseNumberExpr(expr)
 	} else if expr[0] == '[' {
 		// Slice literal
 		if len(expr) < len("[]") || expr[:len("[]")] != "[]" {
@@ -211,8 +982,12 @@ func (ep ExprParser) parseNonOperator(expr string) (Expr, int, error) {
 			return ep.parseStrSliceExpr(expr)
 		} else if len(expr) > len("[]uint") && expr[:len("[]uint")] == "[]uint" {
 			return ep.parseUintSliceExpr(expr)
+		} else if len(expr) > len("[]glob") && expr[:len("[]glob")] == "[]glob" {
+			return ep.parseGlobSliceExpr(expr)
+		} else if len(expr) > len("[]cidr") && expr[:len("[]cidr")] == "[]cidr" {
+			return ep.parseCIDRSliceExpr(expr)
 		} else {
-			return nil, 0, errors.New("expected 'bool', 'string', or 'uint' for slice literal")
+			return nil, 0, errors.New("expected 'bool', 'string', 'uint', 'glob', or 'cidr' for slice literal")
 		}
 	} else {
 		return ep.parseNonLiteral(expr)
@@ -223,12 +998,8 @@ func (ep ExprParser) parseNonOperator(expr string) (Expr, int, error) {
 func (ep ExprParser) parseNonLiteral(expr string) (Expr, int, error) {
 	precondition(len(expr) > 0)
 
-	token, err := ep.nextToken(expr)
-	if err != nil {
-		return nil, 0, err
-	}
-
-	if strings.Contains(token, ".") {
+	name := scanIdentifier(expr)
+	if len(name) < len(expr) && strings.ContainsRune(".[?", rune(expr[len(name)])) {
 		// Struct field reference
 		return ep.parseStructFieldRefExpr(expr)
 	} else {
@@ -261,6 +1032,15 @@ func (ep ExprParser) parseFalseExpr(expr string) (FalseExpr, int, error) {
 	return FalseExpr{}, len("false"), nil
 }
 
+// Parse a 'null' literal expression.
+func (ep ExprParser) parseNullExpr(expr string) (NullExpr, int, error) {
+	if scanIdentifier(expr) != "null" {
+		return NullExpr{}, 0, errors.New("expected 'null'")
+	}
+
+	return NullExpr{}, len("null"), nil
+}
+
 // Parse a string literal expression
 func (ep ExprParser) parseStrExpr(expr string) (StrExpr, int, error) {
 	precondition(len(expr) > 0)
@@ -278,36 +1058,86 @@ func (ep ExprParser) parseStrExpr(expr string) (StrExpr, int, error) {
 	return StrExpr{}, 0, errors.New("expected closing '")
 }
 
-// Parse a uint literal expression.
-func (ep ExprParser) parseUintExpr(expr string) (UintExpr, int, error) {
-	for i, c := range expr {
-		if isTokenTerminator(c) {
-			v, err := strconv.ParseUint(expr[:i], 10, 32)
-			if err != nil {
-				return UintExpr{}, 0, errors.New("invalid integer literal")
-			}
-			return UintExpr{Value: uint(v)}, i, nil
-		}
+// Parse a numeric literal expression. Literals containing a decimal point or
+// an exponent are floats, those with a leading '-' are signed integers, and
+// all others are unsigned integers. Integers may be written in hexadecimal
+// with a '0x' prefix, and may use '_' to separate digits, e.g. 1_000_000.
+func (ep ExprParser) parseNumberExpr(expr string) (Expr, int, error) {
+	token, err := ep.nextToken(expr)
+	if err != nil {
+		return nil, 0, err
+	}
 
-		// The literal consumed the entire raw expression
-		if i == len(expr)-1 {
-			if !unicode.IsDigit(c) {
-				return UintExpr{}, 0, errors.New("expected digit")
-			} else {
-				v, err := strconv.ParseUint(expr, 10, 32)
-				if err != nil {
-					return UintExpr{}, 0, errors.New("invalid integer literal")
-				}
-				return UintExpr{Value: uint(v)}, len(expr), nil
-			}
-		}
+	isHex := strings.HasPrefix(strings.TrimPrefix(token, "-"), "0x")
+	if !isHex && strings.ContainsAny(token, ".eE") {
+		return ep.parseFloatExpr(expr)
+	} else if strings.HasPrefix(token, "-") {
+		return ep.parseIntExpr(expr)
+	} else {
+		return ep.parseUintExpr(expr)
+	}
+}
+
+// Parse a signed integer literal expression.
+func (ep ExprParser) parseIntExpr(expr string) (IntExpr, int, error) {
+	token, err := ep.nextToken(expr)
+	if err != nil {
+		return IntExpr{}, 0, err
+	}
+
+	if !strings.HasPrefix(token, "-") {
+		return IntExpr{}, 0, errors.New("expected '-'")
+	}
+
+	magnitude, err := parseUintLiteral(token[1:])
+	if err != nil {
+		return IntExpr{}, 0, err
+	}
+	if magnitude > -math.MinInt64 {
+		return IntExpr{}, 0, fmt.Errorf("integer literal %s overflows int64", token)
+	}
+
+	return IntExpr{Value: -int64(magnitude)}, len(token), nil
+}
+
+// Parse a floating point literal expression.
+func (ep ExprParser) parseFloatExpr(expr string) (FloatExpr, int, error) {
+	token, err := ep.nextToken(expr)
+	if err != nil {
+		return FloatExpr{}, 0, err
+	}
 
-		if !unicode.IsDigit(c) {
-			return UintExpr{}, 0, errors.New("expected digit")
+	// Only plain decimal notation is accepted
+	for _, c := range token {
+		if !unicode.IsDigit(c) && !strings.ContainsRune("-+.eE", c) {
+			return FloatExpr{}, 0, errors.New("invalid float literal")
 		}
 	}
 
-	return UintExpr{}, len(expr), nil
+	v, err := strconv.ParseFloat(token, 64)
+	if err != nil {
+		return FloatExpr{}, 0, errors.New("invalid float literal")
+	}
+
+	return FloatExpr{Value: v}, len(token), nil
+}
+
+// Parse a uint literal expression.
+func (ep ExprParser) parseUintExpr(expr string) (UintExpr, int, error) {
+	token, err := ep.nextToken(expr)
+	if err != nil {
+		return UintExpr{}, 0, err
+	}
+
+	v, err := parseUintLiteral(token)
+	if err != nil {
+		return UintExpr{}, 0, err
+	}
+	if v > math.MaxUint {
+		return UintExpr{}, 0, fmt.Errorf("integer literal %s overflows uint", token)
+	}
+
+	return UintExpr{Value: uint(v)}, len(token), nil
 }
 
 // Parse a boolean slice literal expression.
@@ -322,14 +1152,15 @@ func (ep ExprParser) parseBoolSliceExpr(expr string) (BoolSliceExpr, int, error)
 	}
 	consumed++
 
-	cb := func(token string) (Expr, int, error) {
-		if token == "true" {
-			return ep.parseTrueExpr(token)
-		} else if token == "false" {
-			return ep.parseFalseExpr(token)
-		} else {
-			return BoolSliceExpr{}, 0, errors.New("expected 'true' or 'false' for boolean literal")
-		}
+	cb := func(rest string) (Expr, int, error) {
+		return ep.parseSliceElement(rest, "bool", func(e Expr) bool {
+			switch e.(type) {
+			case TrueExpr, FalseExpr:
+				return true
+			default:
+				return false
+			}
+		})
 	}
 
 	exprs, n, err := ep.parseExpressionSequence(expr[consumed:], '}', cb)
@@ -353,8 +1184,11 @@ func (ep ExprParser) parseStrSliceExpr(expr string) (StrSliceExpr, int, error) {
 	}
 	consumed++
 
-	cb := func(token string) (Expr, int, error) {
-		return ep.parseStrExpr(token)
+	cb := func(rest string) (Expr, int, error) {
+		return ep.parseSliceElement(rest, "string", func(e Expr) bool {
+			_, ok := e.(StrExpr)
+			return ok
+		})
 	}
 
 	exprs, n, err := ep.parseExpressionSequence(expr[consumed:], '}', cb)
@@ -377,8 +1211,11 @@ func (ep ExprParser) parseUintSliceExpr(expr string) (UintSliceExpr, int, error)
 	}
 	consumed++
 
-	cb := func(token string) (Expr, int, error) {
-		return ep.parseUintExpr(token)
+	cb := func(rest string) (Expr, int, error) {
+		return ep.parseSliceElement(rest, "uint", func(e Expr) bool {
+			_, ok := e.(UintExpr)
+			return ok
+		})
 	}
 
 	exprs, n, err := ep.parseExpressionSequence(expr[consumed:], '}', cb)
@@ -390,37 +1227,201 @@ func (ep ExprParser) parseUintSliceExpr(expr string) (UintSliceExpr, int, error)
 	return UintSliceExpr{Values: exprs}, consumed, nil
 }
 
+// Parse a glob pattern slice literal expression.
+func (ep ExprParser) parseGlobSliceExpr(expr string) (GlobSliceExpr, int, error) {
+	precondition(len(expr) > len("[]glob"))
+	consumed := len("[]glob")
+
+	// Consume the opening brace
+	if expr[consumed] != '{' {
+		return GlobSliceExpr{}, 0, errors.New("expected '{'")
+	}
+	consumed++
+
+	cb := func(rest string) (Expr, int, error) {
+		return ep.parseStrExpr(rest)
+	}
+
+	exprs, n, err := ep.parseExpressionSequence(expr[consumed:], '}', cb)
+	if err != nil {
+		return GlobSliceExpr{}, 0, err
+	}
+	consumed += n
+
+	patterns := make([]Glob, 0, len(exprs))
+	for _, e := range exprs {
+		g, err := CompileGlob(e.(StrExpr).Value)
+		if err != nil {
+			return GlobSliceExpr{}, 0, fmt.Errorf("invalid glob pattern: %w", err)
+		}
+		patterns = append(patterns, g)
+	}
+
+	return GlobSliceExpr{Patterns: patterns}, consumed, nil
+}
+
+// Parse a CIDR network slice literal expression.
+func (ep ExprParser) parseCIDRSliceExpr(expr string) (CIDRSliceExpr, int, error) {
+	precondition(len(expr) > len("[]cidr"))
+	consumed := len("[]cidr")
+
+	// Consume the opening brace
+	if expr[consumed] != '{' {
+		return CIDRSliceExpr{}, 0, errors.New("expected '{'")
+	}
+	consumed++
+
+	cb := func(rest string) (Expr, int, error) {
+		return ep.parseStrExpr(rest)
+	}
+
+	exprs, n, err := ep.parseExpressionSequence(expr[consumed:], '}', cb)
+	if err != nil {
+		return CIDRSliceExpr{}, 0, err
+	}
+	consumed += n
+
+	prefixes := make([]netip.Prefix, 0, len(exprs))
+	for _, e := range exprs {
+		prefix, err := parseCIDR(e.(StrExpr).Value)
+		if err != nil {
+			return CIDRSliceExpr{}, 0, err
+		}
+		prefixes = append(prefixes, prefix)
+	}
+
+	return CIDRSliceExpr{Prefixes: prefixes}, consumed, nil
+}
+
+// Parse an element of a bool, string or uint slice literal. An element may be
+// any expression, which is coerced to the element type on evaluation, but a
+// literal element must satisfy the given check.
+func (ep ExprParser) parseSliceElement(expr string, typeName string, literalOk func(Expr) bool) (Expr, int, error) {
+	element, n, err := ep.parseExpr(expr)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if isLiteral(element) && !literalOk(element) {
+		return nil, 0, fmt.Errorf("expected %s literal in []%s slice literal", typeName, typeName)
+	}
+
+	return element, n, nil
+}
+
+// Determine if an expression is a literal value.
+func isLiteral(e Expr) bool {
+	switch e.(type) {
+	case TrueExpr, FalseExpr, NullExpr, StrExpr, UintExpr, IntExpr, FloatExpr, TimeExpr, DurationExpr,
+		BoolSliceExpr, StrSliceExpr, UintSliceExpr, GlobSliceExpr, CIDRSliceExpr:
+		return true
+	default:
+		return false
+	}
+}
+
 // Parse a variable ref expression.
 func (ep ExprParser) parseVariableRefExpr(expr string) (VariableRefExpr, int, error) {
-	for i, c := range expr {
-		if isTokenTerminator(c) {
-			return VariableRefExpr{Name: expr[:i]}, i, nil
-		} else if i == len(expr)-1 {
-			return VariableRefExpr{Name: expr[:i+1]}, i + 1, nil
-		}
+	name := scanIdentifier(expr)
+	if len(name) == 0 {
+		return VariableRefExpr{}, 0, errors.New("expected expression")
 	}
 
-	return VariableRefExpr{}, 0, errors.New("unexpected end of input")
+	return VariableRefExpr{Name: name}, len(name), nil
 }
 
-// Parse a struct field reference expression.
+// Parse a struct field reference expression. A reference begins with a
+// variable name followed by any number of `.field`, `['key']`, or `[index]`
+// segments, each of which may be prefixed with '?' to make it optional.
 func (ep ExprParser) parseStructFieldRefExpr(expr string) (StructFieldRefExpr, int, error) {
-	var variable string
-	for i, c := range expr {
-		if isTokenTerminator(c) {
-			variable = expr[:i]
-			break
-		} else if i == len(expr)-1 {
-			variable = expr
+	variable := scanIdentifier(expr)
+	if len(variable) == 0 {
+		return StructFieldRefExpr{}, 0, errors.New("expected variable name")
+	}
+	consumed := len(variable)
+
+	path := make([]PathSegment, 0)
+	for consumed < len(expr) && !isTokenTerminator(rune(expr[consumed])) {
+		optional := false
+		if expr[consumed] == '?' {
+			optional = true
+			consumed++
+			if consumed >= len(expr) || (expr[consumed] != '.' && expr[consumed] != '[') {
+				return StructFieldRefExpr{}, 0, fmt.Errorf("expected '.' or '[' after '?' in reference to %s", variable)
+			}
+		}
+
+		switch expr[consumed] {
+		case '.':
+			consumed++
+			field := scanIdentifier(expr[consumed:])
+			if len(field) == 0 {
+				return StructFieldRefExpr{}, 0, fmt.Errorf("expected field name after '.' in reference to %s", variable)
+			}
+			consumed += len(field)
+			path = append(path, PathSegment{Name: field, Optional: optional})
+		case '[':
+			consumed++
+			segment, n, err := ep.parseIndexSegment(expr[consumed:])
+			if err != nil {
+				return StructFieldRefExpr{}, 0, err
+			}
+			consumed += n
+			segment.Optional = optional
+			path = append(path, segment)
+		default:
+			return StructFieldRefExpr{}, 0, fmt.Errorf("unexpected character '%c' in reference to %s", expr[consumed], variable)
+		}
+	}
+
+	return StructFieldRefExpr{VarName: variable, Path: path, JSONTags: ep.JSONTags}, consumed, nil
+}
+
+// Parse the remainder of a bracketed path segment, following the opening
+// '[', through the closing ']'.
+func (ep ExprParser) parseIndexSegment(expr string) (PathSegment, int, error) {
+	if len(expr) == 0 {
+		return PathSegment{}, 0, errors.New("unexpected end of input")
+	}
+
+	var segment PathSegment
+	consumed := 0
+	if expr[0] == '\'' {
+		// Map key or field name
+		key, n, err := ep.parseStrExpr(expr)
+		if err != nil {
+			return PathSegment{}, 0, err
+		}
+		segment = PathSegment{Name: key.Value}
+		consumed += n
+	} else {
+		// Slice or array index
+		for consumed < len(expr) && unicode.IsDigit(rune(expr[consumed])) {
+			consumed++
+		}
+		index, err := strconv.Atoi(expr[:consumed])
+		if err != nil {
+			return PathSegment{}, 0, errors.New("expected string literal key or non-negative integer index")
 		}
+		segment = PathSegment{Index: index, IsIndex: true}
 	}
 
-	parts := strings.Split(variable, ".")
-	if len(parts) != 2 {
-		return StructFieldRefExpr{}, 0, fmt.Errorf("invalid struct field reference: %s", variable)
+	if consumed >= len(expr) || expr[consumed] != ']' {
+		return PathSegment{}, 0, errors.New("expected ']'")
 	}
+	consumed++
+
+	return segment, consumed, nil
+}
 
-	return StructFieldRefExpr{VarName: parts[0], FieldName: parts[1]}, len(variable), nil
+// Scan an identifier from the start of the input.
+func scanIdentifier(expr string) string {
+	for i, c := range expr {
+		if isTokenTerminator(c) || strings.ContainsRune(".[]'?", c) {
+			return expr[:i]
+		}
+	}
+	return expr
 }
 
 // ----------------------------------------------------------------------------
@@ -428,7 +1429,10 @@ func (ep ExprParser) parseStructFieldRefExpr(expr string) (StructFieldRefExpr, i
 // ----------------------------------------------------------------------------
 
 // Parse a sequence of expressions separated by commas.
-func (ep ExprParser) parseExpressionSequence(expr string, terminator byte, tokenCb func(string) (Expr, int, error)) ([]Expr, int, error) {
+//
+// The callback receives the remaining input, beginning at the next
+// expression, and reports the number of bytes it consumed.
+func (ep ExprParser) parseExpressionSequence(expr string, terminator byte, exprCb func(string) (Expr, int, error)) ([]Expr, int, error) {
 	consumed := 0
 
 	exprs := make([]Expr, 0)
@@ -461,12 +1465,7 @@ func (ep ExprParser) parseExpressionSequence(expr string, terminator byte, token
 			continue
 		}
 
-		token, err := ep.nextToken(expr[consumed:])
-		if err != nil {
-			return nil, 0, err
-		}
-
-		newExpr, n, err := tokenCb(token)
+		newExpr, n, err := exprCb(expr[consumed:])
 		if err != nil {
 			return nil, 0, err
 		}
@@ -509,6 +1508,53 @@ func (ep ExprParser) parseExpressionPair(expr string) (Expr, Expr, int, error) {
 	return left, right, consumed, nil
 }
 
+// Parse the digits of an unsigned integer literal into a uint64.
+func parseUintLiteral(token string) (uint64, error) {
+	base := 10
+	digits := token
+	if strings.HasPrefix(token, "0x") {
+		base = 16
+		digits = token[len("0x"):]
+	}
+
+	if len(digits) == 0 {
+		return 0, fmt.Errorf("invalid integer literal: %s", token)
+	}
+
+	// Underscores may only appear between digits
+	if digits[0] == '_' || digits[len(digits)-1] == '_' || strings.Contains(digits, "__") {
+		return 0, fmt.Errorf("invalid digit separator in integer literal: %s", token)
+	}
+
+	v, err := strconv.ParseUint(strings.ReplaceAll(digits, "_", ""), base, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		return 0, fmt.Errorf("integer literal %s overflows uint64", token)
+	} else if err != nil {
+		return 0, fmt.Errorf("invalid integer literal: %s", token)
+	}
+
+	return v, nil
+}
+
+// Parse a network in CIDR notation, normalizing it to its masked form.
+// IPv4-mapped IPv6 networks are unmapped to match coerceAddr, which unmaps
+// the addresses they are tested against.
+func parseCIDR(s string) (netip.Prefix, error) {
+	prefix, err := netip.ParsePrefix(s)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid CIDR network: %w", err)
+	}
+
+	if prefix.Addr().Is4In6() {
+		if prefix.Bits() < 96 {
+			return netip.Prefix{}, fmt.Errorf("invalid CIDR network: IPv4-mapped prefix %s is shorter than /96", s)
+		}
+		prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+	}
+
+	return prefix.Masked(), nil
+}
+
 // Determine if a character is a token terminator.
 func isTokenTerminator(c rune) bool {
 	return c == ' ' || c == ',' || c == ')' || c == '(' || c == '}'
@@ -2,7 +2,11 @@ package authz
 
 import (
 	"errors"
+	"math"
+	"net/netip"
+	"regexp"
 	"testing"
+	"time"
 )
 
 // ExprParser can parse $true() and $false() expressions.
@@ -100,6 +104,129 @@ func TestParseInt(t *testing.T) {
 		{"123", UintExpr{123}, nil},
 		{"123,", nil, errors.New("")},
 		{"123 ", nil, errors.New("")},
+		{"4294967296", UintExpr{4294967296}, nil},
+		{"18446744073709551615", UintExpr{math.MaxUint64}, nil},
+		{"18446744073709551616", nil, errors.New("")},
+		{"0x1F", UintExpr{31}, nil},
+		{"0xffff_ffff_ffff_ffff", UintExpr{math.MaxUint64}, nil},
+		{"0x1_0000_0000_0000_0000", nil, errors.New("")},
+		{"1_000_000", UintExpr{1000000}, nil},
+		{"007", UintExpr{7}, nil},
+		{"-0x10", IntExpr{-16}, nil},
+		{"-1_000", IntExpr{-1000}, nil},
+		{"0x", nil, errors.New("")},
+		{"0xG", nil, errors.New("")},
+		{"1_", nil, errors.New("")},
+		{"1__0", nil, errors.New("")},
+		{"0x_1", nil, errors.New("")},
+		{"12a", nil, errors.New("")},
+	}
+	for _, d := range data {
+		t.Run(d.input, func(t *testing.T) {
+			b := ExprParser{}
+			got, err := b.Parse(d.input)
+
+			if err != nil {
+				if d.expectError == nil {
+					t.Fatalf("unexpected error: %v", err)
+				} else {
+					return
+				}
+			}
+
+			if d.expectError != nil {
+				if err == nil {
+					t.Fatalf("expected error: %v", d.expectError)
+				} else {
+					return
+				}
+			}
+
+			if !got.Equal(d.want) {
+				t.Fatalf("got %v, want %v", got, d.want)
+			}
+		})
+	}
+}
+
+// ExprParser can parse signed integer and float literal expressions.
+func TestParseSignedAndFloat(t *testing.T) {
+	data := []struct {
+		input       string
+		want        Expr
+		expectError error
+	}{
+		{"", nil, errors.New("")},
+		{"-1", IntExpr{-1}, nil},
+		{"-9223372036854775808", IntExpr{math.MinInt64}, nil},
+		{"-9223372036854775809", nil, errors.New("")},
+		{"1.5", FloatExpr{1.5}, nil},
+		{"-0.25", FloatExpr{-0.25}, nil},
+		{"1e3", FloatExpr{1000}, nil},
+		{"2.5E-1", FloatExpr{0.25}, nil},
+		{"1e400", nil, errors.New("")},
+		{"1.2.3", nil, errors.New("")},
+		{"-", nil, errors.New("")},
+		{"--1", nil, errors.New("")},
+		{"-1a", nil, errors.New("")},
+		{"-1,", nil, errors.New("")},
+		{"1.5 ", nil, errors.New("")},
+		{"$lt(balance, -100.5)", LtExpr{VariableRefExpr{"balance"}, FloatExpr{-100.5}}, nil},
+	}
+	for _, d := range data {
+		t.Run(d.input, func(t *testing.T) {
+			b := ExprParser{}
+			got, err := b.Parse(d.input)
+
+			if err != nil {
+				if d.expectError == nil {
+					t.Fatalf("unexpected error: %v", err)
+				} else {
+					return
+				}
+			}
+
+			if d.expectError != nil {
+				if err == nil {
+					t.Fatalf("expected error: %v", d.expectError)
+				} else {
+					return
+				}
+			}
+
+			if !got.Equal(d.want) {
+				t.Fatalf("got %v, want %v", got, d.want)
+			}
+		})
+	}
+}
+
+// ExprParser can parse timestamp and duration literal expressions.
+func TestParseTime(t *testing.T) {
+	data := []struct {
+		input       string
+		want        Expr
+		expectError error
+	}{
+		{"", nil, errors.New("")},
+		{"$time('2024-01-02T03:04:05Z')", TimeExpr{time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)}, nil},
+		{"$time('2024-01-02T04:04:05+01:00')", TimeExpr{time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)}, nil},
+		{"$time('2024-01-02T03:04:05.5Z')", TimeExpr{time.Date(2024, 1, 2, 3, 4, 5, 500000000, time.UTC)}, nil},
+		{"$time('2024-01-02')", nil, errors.New("")},
+		{"$time(value)", nil, errors.New("")},
+		{"$time('2024-01-02T03:04:05Z'", nil, errors.New("")},
+		{"$duration('15m')", DurationExpr{15 * time.Minute}, nil},
+		{"$duration('1h30m')", DurationExpr{90 * time.Minute}, nil},
+		{"$duration('-5s')", DurationExpr{-5 * time.Second}, nil},
+		{"$duration('15')", nil, errors.New("")},
+		{"$duration()", nil, errors.New("")},
+		{"$now()", NowExpr{}, nil},
+		{"$now", nil, errors.New("")},
+		{"$now(1)", nil, errors.New("")},
+		{"$add($now(), $duration('1h'))", AddExpr{NowExpr{}, DurationExpr{time.Hour}}, nil},
+		{"$sub(token.IssuedAt, $duration('1h'))", SubExpr{StructFieldRefExpr{VarName: "token", Path: []PathSegment{{Name: "IssuedAt"}}}, DurationExpr{time.Hour}}, nil},
+		{"$add(1)", nil, errors.New("")},
+		{"$sub(1, 2, 3)", nil, errors.New("")},
 	}
 	for _, d := range data {
 		t.Run(d.input, func(t *testing.T) {
@@ -146,6 +273,8 @@ func TestParseBoolSlice(t *testing.T) {
 		{"[]bool{true", nil, errors.New("")},
 		{"[]bool{1}", nil, errors.New("")},
 		{"[]bool{'hello'}", nil, errors.New("")},
+		{"[]bool{user.Active, $eq(a, b)}", BoolSliceExpr{[]Expr{StructFieldRefExpr{VarName: "user", Path: []PathSegment{{Name: "Active"}}}, EqExpr{VariableRefExpr{"a"}, VariableRefExpr{"b"}}}}, nil},
+		{"[]bool{null}", nil, errors.New("")},
 	}
 	for _, d := range data {
 		t.Run(d.input, func(t *testing.T) {
@@ -186,10 +315,17 @@ func TestParseStringSlice(t *testing.T) {
 		{"[]str{}", StrSliceExpr{[]Expr{}}, nil},
 		{"[]str{'foo'}", StrSliceExpr{[]Expr{StrExpr{"foo"}}}, nil},
 		{"[]str{'foo', 'bar'}", StrSliceExpr{[]Expr{StrExpr{"foo"}, StrExpr{"bar"}}}, nil},
+		{"[]str{'foo bar'}", StrSliceExpr{[]Expr{StrExpr{"foo bar"}}}, nil},
 		{"[]str{'foo',}", nil, errors.New("")},
 		{"[]str{'foo", nil, errors.New("")},
 		{"[]str{1}", nil, errors.New("")},
 		{"[]str{true}", nil, errors.New("")},
+		{"[]str{user.Email, 'admin@corp'}", StrSliceExpr{[]Expr{StructFieldRefExpr{VarName: "user", Path: []PathSegment{{Name: "Email"}}}, StrExpr{"admin@corp"}}}, nil},
+		{"[]str{owner,'admin'}", StrSliceExpr{[]Expr{VariableRefExpr{"owner"}, StrExpr{"admin"}}}, nil},
+		{"[]str{$coalesce(alias, name)}", StrSliceExpr{[]Expr{CoalesceExpr{[]Expr{VariableRefExpr{"alias"}, VariableRefExpr{"name"}}}}}, nil},
+		{"[]str{[]str{'a'}}", nil, errors.New("")},
+		{"[]str{-1.5}", nil, errors.New("")},
+		{"[]str{owner", nil, errors.New("")},
 	}
 	for _, d := range data {
 		t.Run(d.input, func(t *testing.T) {
@@ -230,10 +366,101 @@ func TestParseUintSlice(t *testing.T) {
 		{"[]uint{}", UintSliceExpr{[]Expr{}}, nil},
 		{"[]uint{123}", UintSliceExpr{[]Expr{UintExpr{123}}}, nil},
 		{"[]uint{123, 456}", UintSliceExpr{[]Expr{UintExpr{123}, UintExpr{456}}}, nil},
+		{"[]uint{0xdead_beef, 9_007_199_254_740_993}", UintSliceExpr{[]Expr{UintExpr{0xdeadbeef}, UintExpr{9007199254740993}}}, nil},
+		{"[]uint{18446744073709551615}", UintSliceExpr{[]Expr{UintExpr{math.MaxUint64}}}, nil},
+		{"[]uint{18446744073709551616}", nil, errors.New("")},
+		{"[]uint{-1}", nil, errors.New("")},
 		{"[]uint{123,}", nil, errors.New("")},
 		{"[]uint{123", nil, errors.New("")},
 		{"[]uint{'foo'}", nil, errors.New("")},
 		{"[]uint{true}", nil, errors.New("")},
+		{"[]uint{quota, 1}", UintSliceExpr{[]Expr{VariableRefExpr{"quota"}, UintExpr{1}}}, nil},
+		{"[]uint{1.5}", nil, errors.New("")},
+	}
+	for _, d := range data {
+		t.Run(d.input, func(t *testing.T) {
+			b := ExprParser{}
+			got, err := b.Parse(d.input)
+
+			if err != nil {
+				if d.expectError == nil {
+					t.Fatalf("unexpected error: %v", err)
+				} else {
+					return
+				}
+			}
+
+			if d.expectError != nil {
+				if err == nil {
+					t.Fatalf("expected error: %v", d.expectError)
+				} else {
+					return
+				}
+			}
+
+			if !got.Equal(d.want) {
+				t.Fatalf("got %v, want %v", got, d.want)
+			}
+		})
+	}
+}
+
+// ExprParser can parse glob slice literal expressions.
+func TestParseGlobSlice(t *testing.T) {
+	data := []struct {
+		input       string
+		want        Expr
+		expectError error
+	}{
+		{"", nil, errors.New("")},
+		{"[]glob{}", GlobSliceExpr{[]Glob{}}, nil},
+		{"[]glob{'documents/*/read', 'bucket/**'}", GlobSliceExpr{[]Glob{MustCompileGlob("documents/*/read"), MustCompileGlob("bucket/**")}}, nil},
+		{"[]glob{'a**'}", nil, errors.New("")},
+		{"[]glob{1}", nil, errors.New("")},
+		{"[]glob{'a'", nil, errors.New("")},
+	}
+	for _, d := range data {
+		t.Run(d.input, func(t *testing.T) {
+			b := ExprParser{}
+			got, err := b.Parse(d.input)
+
+			if err != nil {
+				if d.expectError == nil {
+					t.Fatalf("unexpected error: %v", err)
+				} else {
+					return
+				}
+			}
+
+			if d.expectError != nil {
+				if err == nil {
+					t.Fatalf("expected error: %v", d.expectError)
+				} else {
+					return
+				}
+			}
+
+			if !got.Equal(d.want) {
+				t.Fatalf("got %v, want %v", got, d.want)
+			}
+		})
+	}
+}
+
+// ExprParser can parse CIDR slice literal expressions.
+func TestParseCIDRSlice(t *testing.T) {
+	data := []struct {
+		input       string
+		want        Expr
+		expectError error
+	}{
+		{"", nil, errors.New("")},
+		{"[]cidr{}", CIDRSliceExpr{[]netip.Prefix{}}, nil},
+		{"[]cidr{'10.0.0.0/8', 'fd00::/8'}", CIDRSliceExpr{[]netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("fd00::/8")}}, nil},
+		{"[]cidr{'::ffff:192.168.0.0/112'}", CIDRSliceExpr{[]netip.Prefix{netip.MustParsePrefix("192.168.0.0/16")}}, nil},
+		{"[]cidr{'10.0.0.0'}", nil, errors.New("")},
+		{"[]cidr{8}", nil, errors.New("")},
+		{"[]cidr{'10.0.0.0/8',}", nil, errors.New("")},
 	}
 	for _, d := range data {
 		t.Run(d.input, func(t *testing.T) {
@@ -308,6 +535,102 @@ func TestParseEq(t *testing.T) {
 	}
 }
 
+// ExprParser can parse inequality expressions.
+func TestParseNe(t *testing.T) {
+	data := []struct {
+		input       string
+		want        Expr
+		expectError error
+	}{
+		{"", nil, errors.New("")},
+		{"$ne(true, false)", NeExpr{TrueExpr{}, FalseExpr{}}, nil},
+		{"$ne('foo', 123)", NeExpr{StrExpr{"foo"}, UintExpr{123}}, nil},
+		{"$ne(role, 'admin')", NeExpr{VariableRefExpr{"role"}, StrExpr{"admin"}}, nil},
+		{"$ne(", nil, errors.New("")},
+		{"$ne(true", nil, errors.New("")},
+		{"$ne(true),", nil, errors.New("")},
+		{"$ne() ", nil, errors.New("")},
+	}
+	for _, d := range data {
+		t.Run(d.input, func(t *testing.T) {
+			b := ExprParser{}
+			got, err := b.Parse(d.input)
+
+			if err != nil {
+				if d.expectError == nil {
+					t.Fatalf("unexpected error: %v", err)
+				} else {
+					return
+				}
+			}
+
+			if d.expectError != nil {
+				if err == nil {
+					t.Fatalf("expected error: %v", d.expectError)
+				} else {
+					return
+				}
+			}
+
+			if !got.Equal(d.want) {
+				t.Fatalf("got %v, want %v", got, d.want)
+			}
+		})
+	}
+}
+
+// ExprParser can parse ordering comparison expressions.
+func TestParseOrdering(t *testing.T) {
+	data := []struct {
+		input       string
+		want        Expr
+		expectError error
+	}{
+		{"", nil, errors.New("")},
+		{"$lt(1, 2)", LtExpr{UintExpr{1}, UintExpr{2}}, nil},
+		{"$lt('a', 'b')", LtExpr{StrExpr{"a"}, StrExpr{"b"}}, nil},
+		{"$lte(1, 2)", LteExpr{UintExpr{1}, UintExpr{2}}, nil},
+		{"$lte('a', 'b')", LteExpr{StrExpr{"a"}, StrExpr{"b"}}, nil},
+		{"$gt(1, 2)", GtExpr{UintExpr{1}, UintExpr{2}}, nil},
+		{"$gt('a', 'b')", GtExpr{StrExpr{"a"}, StrExpr{"b"}}, nil},
+		{"$gte(1, 2)", GteExpr{UintExpr{1}, UintExpr{2}}, nil},
+		{"$gte('a', 'b')", GteExpr{StrExpr{"a"}, StrExpr{"b"}}, nil},
+		{"$lt(level, 3)", LtExpr{VariableRefExpr{"level"}, UintExpr{3}}, nil},
+		{"$lt", nil, errors.New("")},
+		{"$lt(", nil, errors.New("")},
+		{"$lt(1", nil, errors.New("")},
+		{"$lt(1, 2", nil, errors.New("")},
+		{"$lte(1, 2, 3)", nil, errors.New("")},
+		{"$gt(1, 2) ", nil, errors.New("")},
+	}
+	for _, d := range data {
+		t.Run(d.input, func(t *testing.T) {
+			b := ExprParser{}
+			got, err := b.Parse(d.input)
+
+			if err != nil {
+				if d.expectError == nil {
+					t.Fatalf("unexpected error: %v", err)
+				} else {
+					return
+				}
+			}
+
+			if d.expectError != nil {
+				if err == nil {
+					t.Fatalf("expected error: %v", d.expectError)
+				} else {
+					return
+				}
+			}
+
+			if !got.Equal(d.want) {
+				t.Fatalf("got %v, want %v", got, d.want)
+			}
+		})
+	}
+}
+
 // ExprParser can parse $in expressions.
 func TestParseIn(t *testing.T) {
 	data := []struct {
@@ -318,7 +641,7 @@ func TestParseIn(t *testing.T) {
 		{"", nil, errors.New("")},
 		{"$in(1, []uint{1, 2})", InExpr{UintExpr{1}, UintSliceExpr{[]Expr{UintExpr{1}, UintExpr{2}}}}, nil},
 		{"$in(true, []bool{true, false})", InExpr{TrueExpr{}, BoolSliceExpr{[]Expr{TrueExpr{}, FalseExpr{}}}}, nil},
-		{"$in('foo', []string{'foo', 'bar'})", InExpr{StrExpr{"foo"}, StrSliceExpr{[]Expr{StrExpr{"foo"}, StrExpr{"bar"}}}}, nil},
+		{"$in('foo', []str{'foo', 'bar'})", InExpr{StrExpr{"foo"}, StrSliceExpr{[]Expr{StrExpr{"foo"}, StrExpr{"bar"}}}}, nil},
 		{"$in(", nil, errors.New("")},
 	}
 	for _, d := range data {
@@ -349,23 +672,21 @@ func TestParseIn(t *testing.T) {
 	}
 }
 
-// ExprParser can parse AND expressions.
-func TestParseAnd(t *testing.T) {
+// ExprParser can parse string predicate expressions.
+func TestParseStringPredicates(t *testing.T) {
 	data := []struct {
 		input       string
 		want        Expr
 		expectError error
 	}{
 		{"", nil, errors.New("")},
-		{"$and(true, true)", AndExpr{[]Expr{TrueExpr{}, TrueExpr{}}}, nil},
-		{"$and(true, false)", AndExpr{[]Expr{TrueExpr{}, FalseExpr{}}}, nil},
-		{"$and(1, 2)", AndExpr{[]Expr{UintExpr{1}, UintExpr{2}}}, nil},
-		{"$and('foo', 'bar')", AndExpr{[]Expr{StrExpr{"foo"}, StrExpr{"bar"}}}, nil},
-		{"$and(", nil, errors.New("")},
-		{"$and(true", nil, errors.New("")},
-		{"$and(true),", nil, errors.New("")},
-		{"$and()", AndExpr{[]Expr{}}, nil},
-		{"$and() ", nil, errors.New("")},
+		{"$startsWith(path, 'org/42/')", StartsWithExpr{VariableRefExpr{"path"}, StrExpr{"org/42/"}}, nil},
+		{"$endsWith('foo', 'oo')", EndsWithExpr{StrExpr{"foo"}, StrExpr{"oo"}}, nil},
+		{"$contains(obj.Name, 'bar')", ContainsExpr{StructFieldRefExpr{VarName: "obj", Path: []PathSegment{{Name: "Name"}}}, StrExpr{"bar"}}, nil},
+		{"$startsWith(", nil, errors.New("")},
+		{"$endsWith('foo')", nil, errors.New("")},
+		{"$contains('foo', 'o'", nil, errors.New("")},
+		{"$contains('foo', 'o') ", nil, errors.New("")},
 	}
 	for _, d := range data {
 		t.Run(d.input, func(t *testing.T) {
@@ -395,23 +716,20 @@ func TestParseAnd(t *testing.T) {
 	}
 }
 
-// ExprParser can parse OR expressions.
-func TestParseOr(t *testing.T) {
+// ExprParser can parse $matches expressions.
+func TestParseMatches(t *testing.T) {
 	data := []struct {
 		input       string
 		want        Expr
 		expectError error
 	}{
 		{"", nil, errors.New("")},
-		{"$or(true, true)", OrExpr{[]Expr{TrueExpr{}, TrueExpr{}}}, nil},
-		{"$or(true, false)", OrExpr{[]Expr{TrueExpr{}, FalseExpr{}}}, nil},
-		{"$or(1, 2)", OrExpr{[]Expr{UintExpr{1}, UintExpr{2}}}, nil},
-		{"$or('foo', 'bar')", OrExpr{[]Expr{StrExpr{"foo"}, StrExpr{"bar"}}}, nil},
-		{"$or(", nil, errors.New("")},
-		{"$or(true", nil, errors.New("")},
-		{"$or(true),", nil, errors.New("")},
-		{"$or()", OrExpr{[]Expr{}}, nil},
-		{"$or() ", nil, errors.New("")},
+		{"$matches(email, '@corp\\.com$')", MatchesExpr{VariableRefExpr{"email"}, regexp.MustCompile(`@corp\.com$`)}, nil},
+		{"$matches('t-42', '^t-[0-9]+(,[0-9]+)?$')", MatchesExpr{StrExpr{"t-42"}, regexp.MustCompile(`^t-[0-9]+(,[0-9]+)?$`)}, nil},
+		{"$matches(email, '(')", nil, errors.New("")},
+		{"$matches(email, pattern)", nil, errors.New("")},
+		{"$matches(email)", nil, errors.New("")},
+		{"$matches(email, 'a') ", nil, errors.New("")},
 	}
 	for _, d := range data {
 		t.Run(d.input, func(t *testing.T) {
@@ -441,19 +759,32 @@ func TestParseOr(t *testing.T) {
 	}
 }
 
-// ExprParser can parse variable reference expressions.
-func TestParseVariableRef(t *testing.T) {
+// ExprParser rejects $matches patterns that exceed the configured maximum length.
+func TestParseMatchesMaxLength(t *testing.T) {
+	p := ExprParser{MaxPatternLength: 4}
+
+	if _, err := p.Parse("$matches(v, 'abcd')"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := p.Parse("$matches(v, 'abcde')"); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+// ExprParser can parse $glob expressions.
+func TestParseGlob(t *testing.T) {
 	data := []struct {
 		input       string
 		want        Expr
 		expectError error
 	}{
 		{"", nil, errors.New("")},
-		{"foo", VariableRefExpr{"foo"}, nil},
-		{"foobar", VariableRefExpr{"foobar"}, nil},
-		{"foo,", nil, errors.New("")},
-		{"foo ", nil, errors.New("")},
-		{"foo(", nil, errors.New("")},
+		{"$glob(path, 'documents/*/read')", GlobExpr{VariableRefExpr{"path"}, MustCompileGlob("documents/*/read")}, nil},
+		{"$glob('bucket/a', 'bucket/**')", GlobExpr{StrExpr{"bucket/a"}, MustCompileGlob("bucket/**")}, nil},
+		{"$glob(path, 'bucket/a**')", nil, errors.New("")},
+		{"$glob(path, pattern)", nil, errors.New("")},
+		{"$glob(path)", nil, errors.New("")},
 	}
 	for _, d := range data {
 		t.Run(d.input, func(t *testing.T) {
@@ -483,18 +814,673 @@ func TestParseVariableRef(t *testing.T) {
 	}
 }
 
-// ExprParser can parse struct field reference expressions.
-func TestParseStructFieldRef(t *testing.T) {
+// ExprParser can parse $ipInCidr expressions.
+func TestParseIPInCIDR(t *testing.T) {
 	data := []struct {
 		input       string
 		want        Expr
 		expectError error
 	}{
 		{"", nil, errors.New("")},
-		{"foo.bar", StructFieldRefExpr{"foo", "bar"}, nil},
-		{"foo.bar,", nil, errors.New("")},
-		{"foo.bar.baz ", nil, errors.New("")},
-		{"foo.bar(", nil, errors.New("")},
+		{"$ipInCidr(ip, '10.0.0.0/8')", IPInCIDRExpr{VariableRefExpr{"ip"}, netip.MustParsePrefix("10.0.0.0/8")}, nil},
+		{"$ipInCidr(ip, '10.1.2.3/8')", IPInCIDRExpr{VariableRefExpr{"ip"}, netip.MustParsePrefix("10.0.0.0/8")}, nil},
+		{"$ipInCidr('::1', '2001:db8::/32')", IPInCIDRExpr{StrExpr{"::1"}, netip.MustParsePrefix("2001:db8::/32")}, nil},
+		{"$ipInCidr(ip, '::ffff:10.0.0.0/104')", IPInCIDRExpr{VariableRefExpr{"ip"}, netip.MustParsePrefix("10.0.0.0/8")}, nil},
+		{"$ipInCidr(ip, '::ffff:10.1.2.3/128')", IPInCIDRExpr{VariableRefExpr{"ip"}, netip.MustParsePrefix("10.1.2.3/32")}, nil},
+		{"$ipInCidr(ip, '::ffff:0.0.0.0/95')", nil, errors.New("")},
+		{"$ipInCidr(ip, '10.0.0.0')", nil, errors.New("")},
+		{"$ipInCidr(ip, '10.0.0.0/33')", nil, errors.New("")},
+		{"$ipInCidr(ip, network)", nil, errors.New("")},
+		{"$ipInCidr(ip)", nil, errors.New("")},
+	}
+	for _, d := range data {
+		t.Run(d.input, func(t *testing.T) {
+			b := ExprParser{}
+			got, err := b.Parse(d.input)
+
+			if err != nil {
+				if d.expectError == nil {
+					t.Fatalf("unexpected error: %v", err)
+				} else {
+					return
+				}
+			}
+
+			if d.expectError != nil {
+				if err == nil {
+					t.Fatalf("expected error: %v", d.expectError)
+				} else {
+					return
+				}
+			}
+
+			if !got.Equal(d.want) {
+				t.Fatalf("got %v, want %v", got, d.want)
+			}
+		})
+	}
+}
+
+// ExprParser can parse AND expressions.
+func TestParseAnd(t *testing.T) {
+	data := []struct {
+		input       string
+		want        Expr
+		expectError error
+	}{
+		{"", nil, errors.New("")},
+		{"$and(true, true)", AndExpr{[]Expr{TrueExpr{}, TrueExpr{}}}, nil},
+		{"$and(true, false)", AndExpr{[]Expr{TrueExpr{}, FalseExpr{}}}, nil},
+		{"$and(1, 2)", AndExpr{[]Expr{UintExpr{1}, UintExpr{2}}}, nil},
+		{"$and('foo', 'bar')", AndExpr{[]Expr{StrExpr{"foo"}, StrExpr{"bar"}}}, nil},
+		{"$and($eq(1, 1), $or(false, true))", AndExpr{[]Expr{EqExpr{UintExpr{1}, UintExpr{1}}, OrExpr{[]Expr{FalseExpr{}, TrueExpr{}}}}}, nil},
+		{"$and", nil, errors.New("")},
+		{"$and(", nil, errors.New("")},
+		{"$not($and", nil, errors.New("")},
+		{"$and(true", nil, errors.New("")},
+		{"$and(true),", nil, errors.New("")},
+		{"$and()", AndExpr{[]Expr{}}, nil},
+		{"$and() ", nil, errors.New("")},
+	}
+	for _, d := range data {
+		t.Run(d.input, func(t *testing.T) {
+			b := ExprParser{}
+			got, err := b.Parse(d.input)
+
+			if err != nil {
+				if d.expectError == nil {
+					t.Fatalf("unexpected error: %v", err)
+				} else {
+					return
+				}
+			}
+
+			if d.expectError != nil {
+				if err == nil {
+					t.Fatalf("expected error: %v", d.expectError)
+				} else {
+					return
+				}
+			}
+
+			if !got.Equal(d.want) {
+				t.Fatalf("got %v, want %v", got, d.want)
+			}
+		})
+	}
+}
+
+// ExprParser can parse OR expressions.
+func TestParseOr(t *testing.T) {
+	data := []struct {
+		input       string
+		want        Expr
+		expectError error
+	}{
+		{"", nil, errors.New("")},
+		{"$or(true, true)", OrExpr{[]Expr{TrueExpr{}, TrueExpr{}}}, nil},
+		{"$or", nil, errors.New("")},
+		{"$and(true, $or", nil, errors.New("")},
+		{"$or(true, false)", OrExpr{[]Expr{TrueExpr{}, FalseExpr{}}}, nil},
+		{"$or(1, 2)", OrExpr{[]Expr{UintExpr{1}, UintExpr{2}}}, nil},
+		{"$or('foo', 'bar')", OrExpr{[]Expr{StrExpr{"foo"}, StrExpr{"bar"}}}, nil},
+		{"$or($not(true), $and())", OrExpr{[]Expr{NotExpr{TrueExpr{}}, AndExpr{[]Expr{}}}}, nil},
+		{"$or(", nil, errors.New("")},
+		{"$or(true", nil, errors.New("")},
+		{"$or(true),", nil, errors.New("")},
+		{"$or()", OrExpr{[]Expr{}}, nil},
+		{"$or() ", nil, errors.New("")},
+	}
+	for _, d := range data {
+		t.Run(d.input, func(t *testing.T) {
+			b := ExprParser{}
+			got, err := b.Parse(d.input)
+
+			if err != nil {
+				if d.expectError == nil {
+					t.Fatalf("unexpected error: %v", err)
+				} else {
+					return
+				}
+			}
+
+			if d.expectError != nil {
+				if err == nil {
+					t.Fatalf("expected error: %v", d.expectError)
+				} else {
+					return
+				}
+			}
+
+			if !got.Equal(d.want) {
+				t.Fatalf("got %v, want %v", got, d.want)
+			}
+		})
+	}
+}
+
+// ExprParser can parse NOT expressions.
+func TestParseNot(t *testing.T) {
+	data := []struct {
+		input       string
+		want        Expr
+		expectError error
+	}{
+		{"", nil, errors.New("")},
+		{"$not(true)", NotExpr{TrueExpr{}}, nil},
+		{"$not('foo')", NotExpr{StrExpr{"foo"}}, nil},
+		{"$not($eq(1, 2))", NotExpr{EqExpr{UintExpr{1}, UintExpr{2}}}, nil},
+		{"$not($not(false))", NotExpr{NotExpr{FalseExpr{}}}, nil},
+		{"$not(", nil, errors.New("")},
+		{"$not(true", nil, errors.New("")},
+		{"$not(true, false)", nil, errors.New("")},
+		{"$not()", nil, errors.New("")},
+		{"$not(true) ", nil, errors.New("")},
+	}
+	for _, d := range data {
+		t.Run(d.input, func(t *testing.T) {
+			b := ExprParser{}
+			got, err := b.Parse(d.input)
+
+			if err != nil {
+				if d.expectError == nil {
+					t.Fatalf("unexpected error: %v", err)
+				} else {
+					return
+				}
+			}
+
+			if d.expectError != nil {
+				if err == nil {
+					t.Fatalf("expected error: %v", d.expectError)
+				} else {
+					return
+				}
+			}
+
+			if !got.Equal(d.want) {
+				t.Fatalf("got %v, want %v", got, d.want)
+			}
+		})
+	}
+}
+
+// ExprParser can parse variable reference expressions.
+func TestParseVariableRef(t *testing.T) {
+	data := []struct {
+		input       string
+		want        Expr
+		expectError error
+	}{
+		{"", nil, errors.New("")},
+		{"foo", VariableRefExpr{"foo"}, nil},
+		{"foobar", VariableRefExpr{"foobar"}, nil},
+		{"foo,", nil, errors.New("")},
+		{"foo ", nil, errors.New("")},
+		{"foo(", nil, errors.New("")},
+	}
+	for _, d := range data {
+		t.Run(d.input, func(t *testing.T) {
+			b := ExprParser{}
+			got, err := b.Parse(d.input)
+
+			if err != nil {
+				if d.expectError == nil {
+					t.Fatalf("unexpected error: %v", err)
+				} else {
+					return
+				}
+			}
+
+			if d.expectError != nil {
+				if err == nil {
+					t.Fatalf("expected error: %v", d.expectError)
+				} else {
+					return
+				}
+			}
+
+			if !got.Equal(d.want) {
+				t.Fatalf("got %v, want %v", got, d.want)
+			}
+		})
+	}
+}
+
+// ExprParser can parse struct field reference expressions.
+func TestParseStructFieldRef(t *testing.T) {
+	data := []struct {
+		input       string
+		want        Expr
+		expectError error
+	}{
+		{"", nil, errors.New("")},
+		{"foo.bar", StructFieldRefExpr{VarName: "foo", Path: []PathSegment{{Name: "bar"}}}, nil},
+		{"foo.bar,", nil, errors.New("")},
+		{"foo.bar.baz", StructFieldRefExpr{VarName: "foo", Path: []PathSegment{{Name: "bar"}, {Name: "baz"}}}, nil},
+		{"user.Org.Owner.ID", StructFieldRefExpr{VarName: "user", Path: []PathSegment{{Name: "Org"}, {Name: "Owner"}, {Name: "ID"}}}, nil},
+		{"foo.bar.baz ", nil, errors.New("")},
+		{"claims['key with spaces']", StructFieldRefExpr{VarName: "claims", Path: []PathSegment{{Name: "key with spaces"}}}, nil},
+		{"list[0]", StructFieldRefExpr{VarName: "list", Path: []PathSegment{{Index: 0, IsIndex: true}}}, nil},
+		{"claims['groups'][12].Name", StructFieldRefExpr{VarName: "claims", Path: []PathSegment{{Name: "groups"}, {Index: 12, IsIndex: true}, {Name: "Name"}}}, nil},
+		{"m['a,b)']", StructFieldRefExpr{VarName: "m", Path: []PathSegment{{Name: "a,b)"}}}, nil},
+		{"list[-1]", nil, errors.New("")},
+		{"list[a]", nil, errors.New("")},
+		{"list[0", nil, errors.New("")},
+		{"list[]", nil, errors.New("")},
+		{"m['a'", nil, errors.New("")},
+		{"m['a]", nil, errors.New("")},
+		{"list[0]x", nil, errors.New("")},
+		{"foo]", nil, errors.New("")},
+		{"user?.Manager?.ID", StructFieldRefExpr{VarName: "user", Path: []PathSegment{{Name: "Manager", Optional: true}, {Name: "ID", Optional: true}}}, nil},
+		{"list?[0].Name", StructFieldRefExpr{VarName: "list", Path: []PathSegment{{Index: 0, IsIndex: true, Optional: true}, {Name: "Name"}}}, nil},
+		{"m?['k']", StructFieldRefExpr{VarName: "m", Path: []PathSegment{{Name: "k", Optional: true}}}, nil},
+		{"user?", nil, errors.New("")},
+		{"user??.ID", nil, errors.New("")},
+		{"user?ID", nil, errors.New("")},
+		{"foo..bar", nil, errors.New("")},
+		{"foo.", nil, errors.New("")},
+		{".foo", nil, errors.New("")},
+		{"foo.bar(", nil, errors.New("")},
+	}
+	for _, d := range data {
+		t.Run(d.input, func(t *testing.T) {
+			b := ExprParser{}
+			got, err := b.Parse(d.input)
+
+			if err != nil {
+				if d.expectError == nil {
+					t.Fatalf("unexpected error: %v", err)
+				} else {
+					return
+				}
+			}
+
+			if d.expectError != nil {
+				if err == nil {
+					t.Fatalf("expected error: %v", d.expectError)
+				} else {
+					return
+				}
+			}
+
+			if !got.Equal(d.want) {
+				t.Fatalf("got %v, want %v", got, d.want)
+			}
+		})
+	}
+}
+
+// ExprParser records whether field references may use `json` tags.
+func TestParseStructFieldRefJSONTags(t *testing.T) {
+	p := ExprParser{JSONTags: true}
+
+	got, err := p.Parse("user.tenant_id")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := StructFieldRefExpr{VarName: "user", Path: []PathSegment{{Name: "tenant_id"}}, JSONTags: true}
+	if !got.Equal(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	if got.Equal(StructFieldRefExpr{VarName: "user", Path: []PathSegment{{Name: "tenant_id"}}}) {
+		t.Fatalf("expected references with different tag options to differ")
+	}
+}
+
+// ExprParser can parse null literals, EXISTS and COALESCE expressions.
+func TestParseNullExistsCoalesce(t *testing.T) {
+	data := []struct {
+		input       string
+		want        Expr
+		expectError error
+	}{
+		{"null", NullExpr{}, nil},
+		{"nullable", VariableRefExpr{"nullable"}, nil},
+		{"null.x", nil, errors.New("")},
+		{"$eq(department, null)", EqExpr{VariableRefExpr{"department"}, NullExpr{}}, nil},
+		{"$exists(department)", ExistsExpr{VariableRefExpr{"department"}}, nil},
+		{"$exists(user?.Manager.ID)", ExistsExpr{StructFieldRefExpr{VarName: "user", Path: []PathSegment{{Name: "Manager", Optional: true}, {Name: "ID"}}}}, nil},
+		{"$exists(claims['dept'])", ExistsExpr{StructFieldRefExpr{VarName: "claims", Path: []PathSegment{{Name: "dept"}}}}, nil},
+		{"$exists('dept')", nil, errors.New("")},
+		{"$exists(null)", nil, errors.New("")},
+		{"$exists($eq(a, b))", nil, errors.New("")},
+		{"$exists(a, b)", nil, errors.New("")},
+		{"$exists()", nil, errors.New("")},
+		{"$exists(a", nil, errors.New("")},
+		{"$coalesce(department, 'none')", CoalesceExpr{[]Expr{VariableRefExpr{"department"}, StrExpr{"none"}}}, nil},
+		{"$coalesce(a.B, c, null)", CoalesceExpr{[]Expr{StructFieldRefExpr{VarName: "a", Path: []PathSegment{{Name: "B"}}}, VariableRefExpr{"c"}, NullExpr{}}}, nil},
+		{"$coalesce(a)", CoalesceExpr{[]Expr{VariableRefExpr{"a"}}}, nil},
+		{"$coalesce()", nil, errors.New("")},
+		{"$coalesce(a, b", nil, errors.New("")},
+	}
+	for _, d := range data {
+		t.Run(d.input, func(t *testing.T) {
+			b := ExprParser{}
+			got, err := b.Parse(d.input)
+
+			if err != nil {
+				if d.expectError == nil {
+					t.Fatalf("unexpected error: %v", err)
+				} else {
+					return
+				}
+			}
+
+			if d.expectError != nil {
+				if err == nil {
+					t.Fatalf("expected error: %v", d.expectError)
+				} else {
+					return
+				}
+			}
+
+			if !got.Equal(d.want) {
+				t.Fatalf("got %v, want %v", got, d.want)
+			}
+		})
+	}
+}
+
+// ExprParser can parse set relation expressions.
+func TestParseSetRelations(t *testing.T) {
+	data := []struct {
+		input       string
+		want        Expr
+		expectError error
+	}{
+		{"$subset(user.Scopes, []str{'read', 'write'})", SubsetExpr{StructFieldRefExpr{VarName: "user", Path: []PathSegment{{Name: "Scopes"}}}, StrSliceExpr{[]Expr{StrExpr{"read"}, StrExpr{"write"}}}}, nil},
+		{"$intersects(roles, []str{'admin'})", IntersectsExpr{VariableRefExpr{"roles"}, StrSliceExpr{[]Expr{StrExpr{"admin"}}}}, nil},
+		{"$disjoint(a, b)", DisjointExpr{VariableRefExpr{"a"}, VariableRefExpr{"b"}}, nil},
+		{"$equalSets(a, []uint{1})", EqualSetsExpr{VariableRefExpr{"a"}, UintSliceExpr{[]Expr{UintExpr{1}}}}, nil},
+		{"$subset(a)", nil, errors.New("")},
+		{"$intersects(a, b, c)", nil, errors.New("")},
+		{"$disjoint(a, b", nil, errors.New("")},
+		{"$equalSets", nil, errors.New("")},
+	}
+	for _, d := range data {
+		t.Run(d.input, func(t *testing.T) {
+			b := ExprParser{}
+			got, err := b.Parse(d.input)
+
+			if err != nil {
+				if d.expectError == nil {
+					t.Fatalf("unexpected error: %v", err)
+				} else {
+					return
+				}
+			}
+
+			if d.expectError != nil {
+				if err == nil {
+					t.Fatalf("expected error: %v", d.expectError)
+				} else {
+					return
+				}
+			}
+
+			if !got.Equal(d.want) {
+				t.Fatalf("got %v, want %v", got, d.want)
+			}
+		})
+	}
+}
+
+// ExprParser can parse quantifier expressions.
+func TestParseQuantifiers(t *testing.T) {
+	data := []struct {
+		input       string
+		want        Expr
+		expectError error
+	}{
+		{"$any(user.Memberships, m, $eq(m.Role, 'admin'))", AnyExpr{StructFieldRefExpr{VarName: "user", Path: []PathSegment{{Name: "Memberships"}}}, "m", EqExpr{StructFieldRefExpr{VarName: "m", Path: []PathSegment{{Name: "Role"}}}, StrExpr{"admin"}}}, nil},
+		{"$all(ids, id, $lt(id, 100))", AllExpr{VariableRefExpr{"ids"}, "id", LtExpr{VariableRefExpr{"id"}, UintExpr{100}}}, nil},
+		{"$any([]str{'a'}, x, $all(y, z, $eq(x, z)))", AnyExpr{StrSliceExpr{[]Expr{StrExpr{"a"}}}, "x", AllExpr{VariableRefExpr{"y"}, "z", EqExpr{VariableRefExpr{"x"}, VariableRefExpr{"z"}}}}, nil},
+		{"$any(a, x)", nil, errors.New("")},
+		{"$all(a, x, true, false)", nil, errors.New("")},
+		{"$any(a, x.y, true)", nil, errors.New("")},
+		{"$any(a, 'x', true)", nil, errors.New("")},
+		{"$any(a, true, true)", nil, errors.New("")},
+		{"$any(a, null, true)", nil, errors.New("")},
+		{"$all(a, $x, true)", nil, errors.New("")},
+		{"$all(a, x, true", nil, errors.New("")},
+	}
+	for _, d := range data {
+		t.Run(d.input, func(t *testing.T) {
+			b := ExprParser{}
+			got, err := b.Parse(d.input)
+
+			if err != nil {
+				if d.expectError == nil {
+					t.Fatalf("unexpected error: %v", err)
+				} else {
+					return
+				}
+			}
+
+			if d.expectError != nil {
+				if err == nil {
+					t.Fatalf("expected error: %v", d.expectError)
+				} else {
+					return
+				}
+			}
+
+			if !got.Equal(d.want) {
+				t.Fatalf("got %v, want %v", got, d.want)
+			}
+		})
+	}
+}
+
+// ExprParser can parse length and aggregate expressions.
+func TestParseAggregates(t *testing.T) {
+	data := []struct {
+		input       string
+		want        Expr
+		expectError error
+	}{
+		{"$len(approvals)", LenExpr{VariableRefExpr{"approvals"}}, nil},
+		{"$gte($len(request.Approvals), 2)", GteExpr{LenExpr{StructFieldRefExpr{VarName: "request", Path: []PathSegment{{Name: "Approvals"}}}}, UintExpr{2}}, nil},
+		{"$count(approvals, a, $eq(a.Role, 'lead'))", CountExpr{VariableRefExpr{"approvals"}, "a", EqExpr{StructFieldRefExpr{VarName: "a", Path: []PathSegment{{Name: "Role"}}}, StrExpr{"lead"}}}, nil},
+		{"$sum([]uint{1, 2})", SumExpr{UintSliceExpr{[]Expr{UintExpr{1}, UintExpr{2}}}}, nil},
+		{"$min(prices)", MinExpr{VariableRefExpr{"prices"}}, nil},
+		{"$max(prices)", MaxExpr{VariableRefExpr{"prices"}}, nil},
+		{"$len()", nil, errors.New("")},
+		{"$len(a, b)", nil, errors.New("")},
+		{"$sum(a", nil, errors.New("")},
+		{"$count(a, x)", nil, errors.New("")},
+		{"$count(a, 'x', true)", nil, errors.New("")},
+		{"$max", nil, errors.New("")},
+	}
+	for _, d := range data {
+		t.Run(d.input, func(t *testing.T) {
+			b := ExprParser{}
+			got, err := b.Parse(d.input)
+
+			if err != nil {
+				if d.expectError == nil {
+					t.Fatalf("unexpected error: %v", err)
+				} else {
+					return
+				}
+			}
+
+			if d.expectError != nil {
+				if err == nil {
+					t.Fatalf("expected error: %v", d.expectError)
+				} else {
+					return
+				}
+			}
+
+			if !got.Equal(d.want) {
+				t.Fatalf("got %v, want %v", got, d.want)
+			}
+		})
+	}
+}
+
+// ExprParser can parse numeric arithmetic expressions.
+func TestParseNumericArithmetic(t *testing.T) {
+	data := []struct {
+		input       string
+		want        Expr
+		expectError error
+	}{
+		{"$lte($add(used, requested), limit)", LteExpr{AddExpr{VariableRefExpr{"used"}, VariableRefExpr{"requested"}}, VariableRefExpr{"limit"}}, nil},
+		{"$mul(price, 1.2)", MulExpr{VariableRefExpr{"price"}, FloatExpr{1.2}}, nil},
+		{"$div(total, $sub(count, 1))", DivExpr{VariableRefExpr{"total"}, SubExpr{VariableRefExpr{"count"}, UintExpr{1}}}, nil},
+		{"$mod(id, 2)", ModExpr{VariableRefExpr{"id"}, UintExpr{2}}, nil},
+		{"$mul(a)", nil, errors.New("")},
+		{"$div(a, b, c)", nil, errors.New("")},
+		{"$mod(a, b", nil, errors.New("")},
+	}
+	for _, d := range data {
+		t.Run(d.input, func(t *testing.T) {
+			b := ExprParser{}
+			got, err := b.Parse(d.input)
+
+			if err != nil {
+				if d.expectError == nil {
+					t.Fatalf("unexpected error: %v", err)
+				} else {
+					return
+				}
+			}
+
+			if d.expectError != nil {
+				if err == nil {
+					t.Fatalf("expected error: %v", d.expectError)
+				} else {
+					return
+				}
+			}
+
+			if !got.Equal(d.want) {
+				t.Fatalf("got %v, want %v", got, d.want)
+			}
+		})
+	}
+}
+
+// ExprParser can parse conditional expressions.
+func TestParseConditionals(t *testing.T) {
+	data := []struct {
+		input       string
+		want        Expr
+		expectError error
+	}{
+		{"$if(resource.Public, true, $in(user, resource.Members))", IfExpr{StructFieldRefExpr{VarName: "resource", Path: []PathSegment{{Name: "Public"}}}, TrueExpr{}, InExpr{VariableRefExpr{"user"}, StructFieldRefExpr{VarName: "resource", Path: []PathSegment{{Name: "Members"}}}}}, nil},
+		{"$if(a, 1, 2)", IfExpr{VariableRefExpr{"a"}, UintExpr{1}, UintExpr{2}}, nil},
+		{"$if(a, b)", nil, errors.New("")},
+		{"$if(a, b, c, d)", nil, errors.New("")},
+		{"$if(a, b, c", nil, errors.New("")},
+		{"$switch(kind, 'doc', a, 'folder', b, false)", SwitchExpr{VariableRefExpr{"kind"}, []SwitchCase{{StrExpr{"doc"}, VariableRefExpr{"a"}}, {StrExpr{"folder"}, VariableRefExpr{"b"}}}, FalseExpr{}}, nil},
+		{"$switch(kind, 'doc', a)", SwitchExpr{VariableRefExpr{"kind"}, []SwitchCase{{StrExpr{"doc"}, VariableRefExpr{"a"}}}, nil}, nil},
+		{"$switch(kind, false)", SwitchExpr{VariableRefExpr{"kind"}, []SwitchCase{}, FalseExpr{}}, nil},
+		{"$switch(kind)", nil, errors.New("")},
+		{"$switch()", nil, errors.New("")},
+		{"$switch(kind, 'doc', a", nil, errors.New("")},
+	}
+	for _, d := range data {
+		t.Run(d.input, func(t *testing.T) {
+			b := ExprParser{}
+			got, err := b.Parse(d.input)
+
+			if err != nil {
+				if d.expectError == nil {
+					t.Fatalf("unexpected error: %v", err)
+				} else {
+					return
+				}
+			}
+
+			if d.expectError != nil {
+				if err == nil {
+					t.Fatalf("expected error: %v", d.expectError)
+				} else {
+					return
+				}
+			}
+
+			if !got.Equal(d.want) {
+				t.Fatalf("got %v, want %v", got, d.want)
+			}
+		})
+	}
+}
+
+// ExprParser can parse local binding expressions.
+func TestParseLet(t *testing.T) {
+	data := []struct {
+		input       string
+		want        Expr
+		expectError error
+	}{
+		{"$let(org, user.Org.ID, $eq(org, resource.Org))", LetExpr{"org", StructFieldRefExpr{VarName: "user", Path: []PathSegment{{Name: "Org"}, {Name: "ID"}}}, EqExpr{VariableRefExpr{"org"}, StructFieldRefExpr{VarName: "resource", Path: []PathSegment{{Name: "Org"}}}}}, nil},
+		{"$let(a, 1, $let(b, $add(a, 1), b))", LetExpr{"a", UintExpr{1}, LetExpr{"b", AddExpr{VariableRefExpr{"a"}, UintExpr{1}}, VariableRefExpr{"b"}}}, nil},
+		{"$let(a, 1)", nil, errors.New("")},
+		{"$let(a, 1, a, a)", nil, errors.New("")},
+		{"$let(true, 1, a)", nil, errors.New("")},
+		{"$let(false, 1, a)", nil, errors.New("")},
+		{"$let(null, 1, a)", nil, errors.New("")},
+		{"$let($a, 1, a)", nil, errors.New("")},
+		{"$let(a.b, 1, a)", nil, errors.New("")},
+		{"$let('a', 1, a)", nil, errors.New("")},
+		{"$let(a, 1, a", nil, errors.New("")},
+	}
+	for _, d := range data {
+		t.Run(d.input, func(t *testing.T) {
+			b := ExprParser{}
+			got, err := b.Parse(d.input)
+
+			if err != nil {
+				if d.expectError == nil {
+					t.Fatalf("unexpected error: %v", err)
+				} else {
+					return
+				}
+			}
+
+			if d.expectError != nil {
+				if err == nil {
+					t.Fatalf("expected error: %v", d.expectError)
+				} else {
+					return
+				}
+			}
+
+			if !got.Equal(d.want) {
+				t.Fatalf("got %v, want %v", got, d.want)
+			}
+		})
+	}
+}
+
+// ExprParser can parse string function expressions.
+func TestParseStringFunctions(t *testing.T) {
+	data := []struct {
+		input       string
+		want        Expr
+		expectError error
+	}{
+		{"$eq($lower(user.Email), 'alice@corp.com')", EqExpr{LowerExpr{StructFieldRefExpr{VarName: "user", Path: []PathSegment{{Name: "Email"}}}}, StrExpr{"alice@corp.com"}}, nil},
+		{"$upper(a)", UpperExpr{VariableRefExpr{"a"}}, nil},
+		{"$trim($lower(a))", TrimExpr{LowerExpr{VariableRefExpr{"a"}}}, nil},
+		{"$concat('org/', org, '/doc')", ConcatExpr{[]Expr{StrExpr{"org/"}, VariableRefExpr{"org"}, StrExpr{"/doc"}}}, nil},
+		{"$in('admin', $split(groups, ','))", InExpr{StrExpr{"admin"}, SplitExpr{VariableRefExpr{"groups"}, StrExpr{","}}}, nil},
+		{"$replace(a, '-', '_')", ReplaceExpr{VariableRefExpr{"a"}, StrExpr{"-"}, StrExpr{"_"}}, nil},
+		{"$eqFold(a, 'Admin')", EqFoldExpr{VariableRefExpr{"a"}, StrExpr{"Admin"}}, nil},
+		{"$lower()", nil, errors.New("")},
+		{"$upper(a, b)", nil, errors.New("")},
+		{"$concat()", nil, errors.New("")},
+		{"$split(a)", nil, errors.New("")},
+		{"$replace(a, b)", nil, errors.New("")},
+		{"$eqFold(a, b, c)", nil, errors.New("")},
+		{"$trim(a", nil, errors.New("")},
 	}
 	for _, d := range data {
 		t.Run(d.input, func(t *testing.T) {
@@ -4,40 +4,160 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"strings"
 )
 
-// Get a field from an object by name.
+// The error returned when a field or element of a nil value is accessed.
+var ErrNilDereference = errors.New("nil dereference")
+
+// The error returned when a referenced variable, field, map key or element
+// does not exist.
+var ErrNotFound = errors.New("not found")
+
+// Get a field from an object by name. If the object is a map with string
+// keys, the entry with the given key is returned instead.
+//
+// Struct fields are named by their `authz` struct tag where present, e.g. a
+// field tagged `authz:"tenant_id"` is named "tenant_id" rather than by its Go
+// name, and a field tagged `authz:"-"` cannot be accessed at all, nor can the
+// fields promoted through it if it is an embedded struct. Unexported fields
+// cannot be accessed.
 func GetField(obj interface{}, name string) (interface{}, error) {
-	if !hasKind(obj, []reflect.Kind{reflect.Struct, reflect.Pointer}) {
+	return getField(obj, name, false)
+}
+
+// Get a field from an object by name, as GetField does. If jsonTags is set,
+// fields without an `authz` tag are named by their `json` struct tag, if any.
+func getField(obj interface{}, name string, jsonTags bool) (interface{}, error) {
+	objValue, err := reflectValue(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	if objValue.Kind() == reflect.Map {
+		return getMapEntry(objValue, name)
+	}
+
+	if objValue.Kind() != reflect.Struct {
 		return nil, errors.New("unsupported type")
 	}
 
-	objValue := reflectValue(obj)
+	for _, f := range reflect.VisibleFields(objValue.Type()) {
+		if !f.IsExported() {
+			continue
+		}
+
+		if fieldName, ok := policyFieldName(f, jsonTags); !ok || fieldName != name {
+			continue
+		}
+
+		// Fields promoted through a hidden embedded struct are hidden too
+		if isPromotedThroughHidden(objValue.Type(), f.Index, jsonTags) {
+			continue
+		}
+
+		// This fails only if the field is promoted through a nil embedded pointer
+		field, err := objValue.FieldByIndexErr(f.Index)
+		if err != nil {
+			return nil, fmt.Errorf("%w: embedded struct containing field %s", ErrNilDereference, name)
+		}
 
-	field := objValue.FieldByName(name)
-	if !field.IsValid() {
-		return nil, fmt.Errorf("no such field: %s in obj", name)
+		return field.Interface(), nil
 	}
 
-	return field.Interface(), nil
+	return nil, fmt.Errorf("field %s %w", name, ErrNotFound)
 }
 
-// Reflect a value from an object.
-func reflectValue(obj interface{}) reflect.Value {
-	if reflect.TypeOf(obj).Kind() == reflect.Ptr {
-		return reflect.ValueOf(obj).Elem()
-	} else {
-		return reflect.ValueOf(obj)
+// Determine the name by which a policy refers to a struct field, and whether
+// the field is visible to policies at all.
+func policyFieldName(f reflect.StructField, jsonTags bool) (string, bool) {
+	tagKeys := []string{"authz"}
+	if jsonTags {
+		tagKeys = append(tagKeys, "json")
+	}
+
+	for _, key := range tagKeys {
+		tag, ok := f.Tag.Lookup(key)
+		if !ok {
+			continue
+		}
+
+		tagName, _, _ := strings.Cut(tag, ",")
+		if tagName == "-" {
+			return "", false
+		}
+		if tagName != "" {
+			return tagName, true
+		}
 	}
+
+	return f.Name, true
 }
 
-// Determine if a value has one of a set of Kinds.
-func hasKind(obj interface{}, types []reflect.Kind) bool {
-	for _, t := range types {
-		if reflect.TypeOf(obj).Kind() == t {
+// Determine if a field, identified by its index sequence within a struct
+// type, is promoted through an embedded struct that is hidden from policies.
+func isPromotedThroughHidden(t reflect.Type, index []int, jsonTags bool) bool {
+	for i := 1; i < len(index); i++ {
+		if _, ok := policyFieldName(t.FieldByIndex(index[:i]), jsonTags); !ok {
 			return true
 		}
 	}
-
 	return false
 }
+
+// Get an element from a slice or array by index.
+func GetIndex(obj interface{}, index int) (interface{}, error) {
+	objValue, err := reflectValue(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	if objValue.Kind() != reflect.Slice && objValue.Kind() != reflect.Array {
+		return nil, errors.New("unsupported type")
+	}
+
+	if index < 0 || index >= objValue.Len() {
+		return nil, fmt.Errorf("index %d out of range for length %d: %w", index, objValue.Len(), ErrNotFound)
+	}
+
+	return objValue.Index(index).Interface(), nil
+}
+
+// Get an entry from a map by key.
+func getMapEntry(m reflect.Value, key string) (interface{}, error) {
+	keyType := m.Type().Key()
+	if keyType.Kind() != reflect.String {
+		return nil, fmt.Errorf("unsupported map key type: %v", keyType)
+	}
+
+	entry := m.MapIndex(reflect.ValueOf(key).Convert(keyType))
+	if !entry.IsValid() {
+		return nil, fmt.Errorf("key %s %w", key, ErrNotFound)
+	}
+
+	return entry.Interface(), nil
+}
+
+// Reflect a value from an object, following any pointers and interfaces.
+func reflectValue(obj interface{}) (reflect.Value, error) {
+	v := reflect.ValueOf(obj)
+	if !v.IsValid() {
+		return reflect.Value{}, ErrNilDereference
+	}
+
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return reflect.Value{}, ErrNilDereference
+		}
+		v = v.Elem()
+	}
+
+	return v, nil
+}
+
+// Determine if a value is null, i.e. nil, or a chain of pointers and
+// interfaces that ends in nil. Nil maps and slices are empty, not null.
+func isNil(obj interface{}) bool {
+	_, err := reflectValue(obj)
+	return err != nil
+}
@@ -1,6 +1,7 @@
 package authz
 
 import (
+	"errors"
 	"testing"
 )
 
@@ -44,3 +45,199 @@ func TestGetFieldNonStruct(t *testing.T) {
 		t.Fatalf("expected error")
 	}
 }
+
+// GetField retrieves an entry from a map by key.
+func TestGetFieldMap(t *testing.T) {
+	type Key string
+	obj := map[Key]int{"a": 1}
+
+	v, err := GetField(obj, "a")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if v != 1 {
+		t.Fatalf("got %v, want %v", v, 1)
+	}
+
+	if _, err := GetField(obj, "b"); err == nil {
+		t.Fatalf("expected error")
+	}
+
+	if _, err := GetField(map[int]int{1: 1}, "1"); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+// GetIndex retrieves an element from a slice or array by index.
+func TestGetIndex(t *testing.T) {
+	v, err := GetIndex([]string{"a", "b"}, 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if v != "b" {
+		t.Fatalf("got %v, want %v", v, "b")
+	}
+
+	v, err = GetIndex(&[2]int{3, 4}, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if v != 3 {
+		t.Fatalf("got %v, want %v", v, 3)
+	}
+
+	if _, err := GetIndex([]string{"a"}, 1); err == nil {
+		t.Fatalf("expected error")
+	}
+
+	if _, err := GetIndex("hello", 0); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+type taggedBase struct {
+	Region string `authz:"region"`
+}
+
+type taggedUser struct {
+	taggedBase
+	TenantID string `authz:"tenant_id" json:"tenantId"`
+	Email    string `json:"email,omitempty"`
+	Password string `authz:"-"`
+	Secret   string `json:"-"`
+	Name     string
+	internal string
+}
+
+// GetField names struct fields by their `authz` tags, and optionally their `json` tags.
+func TestGetFieldTags(t *testing.T) {
+	obj := taggedUser{
+		taggedBase: taggedBase{Region: "eu"},
+		TenantID:   "t-1",
+		Email:      "a@b.c",
+		Password:   "hunter2",
+		Secret:     "s",
+		Name:       "alice",
+		internal:   "x",
+	}
+
+	data := []struct {
+		name      string
+		jsonTags  bool
+		want      interface{}
+		expectErr bool
+	}{
+		{"tenant_id", false, "t-1", false},
+		{"tenant_id", true, "t-1", false},
+		{"TenantID", false, nil, true},
+		{"tenantId", true, nil, true},
+		{"Email", false, "a@b.c", false},
+		{"email", false, nil, true},
+		{"email", true, "a@b.c", false},
+		{"Email", true, nil, true},
+		{"Password", false, nil, true},
+		{"Password", true, nil, true},
+		{"Secret", false, "s", false},
+		{"Secret", true, nil, true},
+		{"Name", true, "alice", false},
+		{"region", false, "eu", false},
+		{"Region", false, nil, true},
+		{"internal", false, nil, true},
+	}
+	for _, d := range data {
+		got, err := getField(obj, d.name, d.jsonTags)
+		if err != nil {
+			if !d.expectErr {
+				t.Errorf("%s (json=%v): unexpected error: %v", d.name, d.jsonTags, err)
+			}
+			continue
+		}
+		if d.expectErr {
+			t.Errorf("%s (json=%v): expected error", d.name, d.jsonTags)
+			continue
+		}
+		if got != d.want {
+			t.Errorf("%s (json=%v): got %v, want %v", d.name, d.jsonTags, got, d.want)
+		}
+	}
+}
+
+type hiddenBase struct {
+	Token string
+}
+
+type jsonHiddenBase struct {
+	Key string
+}
+
+type hiddenEmbedUser struct {
+	hiddenBase      `authz:"-"`
+	*jsonHiddenBase `json:"-"`
+	taggedBase
+}
+
+// GetField hides fields promoted through an embedded struct that is itself hidden.
+func TestGetFieldHiddenEmbedded(t *testing.T) {
+	obj := hiddenEmbedUser{hiddenBase{"s3cr3t"}, &jsonHiddenBase{"k"}, taggedBase{"eu"}}
+
+	data := []struct {
+		name      string
+		jsonTags  bool
+		want      interface{}
+		expectErr bool
+	}{
+		{"Token", false, nil, true},
+		{"Token", true, nil, true},
+		{"hiddenBase", false, nil, true},
+		{"Key", false, "k", false},
+		{"Key", true, nil, true},
+		{"region", true, "eu", false},
+	}
+	for _, d := range data {
+		got, err := getField(obj, d.name, d.jsonTags)
+		if err != nil {
+			if !d.expectErr {
+				t.Errorf("%s (json=%v): unexpected error: %v", d.name, d.jsonTags, err)
+			}
+			continue
+		}
+		if d.expectErr {
+			t.Errorf("%s (json=%v): expected error", d.name, d.jsonTags)
+			continue
+		}
+		if got != d.want {
+			t.Errorf("%s (json=%v): got %v, want %v", d.name, d.jsonTags, got, d.want)
+		}
+	}
+}
+
+type embeddedPtrUser struct {
+	*taggedBase
+}
+
+// GetField and GetIndex fail with ErrNilDereference rather than panicking on nil values.
+func TestGetFieldNil(t *testing.T) {
+	var nilUser *taggedUser
+	var nilIface interface{} = nilUser
+	var nilSlice *[]int
+
+	data := []struct {
+		name string
+		fn   func() (interface{}, error)
+	}{
+		{"nil", func() (interface{}, error) { return GetField(nil, "Name") }},
+		{"nil pointer", func() (interface{}, error) { return GetField(nilUser, "Name") }},
+		{"nil pointer in interface", func() (interface{}, error) { return GetField(&nilIface, "Name") }},
+		{"nil embedded pointer", func() (interface{}, error) { return GetField(embeddedPtrUser{}, "region") }},
+		{"nil index", func() (interface{}, error) { return GetIndex(nil, 0) }},
+		{"nil slice pointer", func() (interface{}, error) { return GetIndex(nilSlice, 0) }},
+	}
+	for _, d := range data {
+		t.Run(d.name, func(t *testing.T) {
+			_, err := d.fn()
+			if !errors.Is(err, ErrNilDereference) {
+				t.Fatalf("got %v, want %v", err, ErrNilDereference)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package authz
+
+import (
+	"reflect"
+	"slices"
+)
+
+// Determine if a collection contains an element, i.e. if any of its elements
+// is equal to the element. Every element of the collection must be comparable
+// with the element; see compareEqual.
+func containsElement(collection []interface{}, element interface{}) (bool, error) {
+	found := false
+	for _, e := range collection {
+		eq, err := compareEqual(element, e)
+		if err != nil {
+			return false, err
+		}
+		found = found || eq
+	}
+	return found, nil
+}
+
+// Determine if a collection of strings, unsigned integers or bools contains
+// an element of the same type, without comparing each element as
+// containsElement does. The result is not ok for any other collection or
+// element, which must be compared element by element.
+func containsScalar(collection, element interface{}) (bool, bool) {
+	if s, err := coerceStr(element); err == nil {
+		if elements, err := coerceStrSlice(collection); err == nil {
+			return slices.Contains(elements, s), true
+		}
+	} else if u, err := coerceUint(element); err == nil {
+		if elements, err := coerceUintSlice(collection); err == nil {
+			return slices.Contains(elements, u), true
+		}
+	} else if b, err := coerceBool(element); err == nil {
+		if elements, err := coerceBoolSlice(collection); err == nil {
+			return slices.Contains(elements, b), true
+		}
+	}
+	return false, false
+}
+
+// Determine if a map contains a key equal to an element. If the element
+// coerces to the key type the key is looked up directly; otherwise, e.g. for
+// keys of interface type, each key is compared as by containsElement.
+func containsKey(m reflect.Value, element interface{}) (bool, error) {
+	if key, ok := coerceKey(element, m.Type().Key()); ok {
+		return m.MapIndex(key).IsValid(), nil
+	}
+
+	keys := make([]interface{}, 0, m.Len())
+	for _, key := range m.MapKeys() {
+		keys = append(keys, key.Interface())
+	}
+	return containsElement(keys, element)
+}
+
+// Determine if every element of the left collection is contained in the
+// right collection.
+func isSubset(left, right []interface{}) (bool, error) {
+	for _, e := range left {
+		ok, err := containsElement(right, e)
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// Determine if any element of the left collection is contained in the right
+// collection.
+func intersects(left, right []interface{}) (bool, error) {
+	for _, e := range left {
+		ok, err := containsElement(right, e)
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Determine if two collections contain the same elements, ignoring order
+// and duplicates.
+func equalSets(left, right []interface{}) (bool, error) {
+	ok, err := isSubset(left, right)
+	if err != nil || !ok {
+		return false, err
+	}
+	return isSubset(right, left)
+}
@@ -0,0 +1,61 @@
+package authz
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// Set relations between collections work as expected.
+func TestSetRelations(t *testing.T) {
+	data := []struct {
+		left          []interface{}
+		right         []interface{}
+		wantSubset    bool
+		wantIntersect bool
+		wantEqual     bool
+		expectError   error
+	}{
+		{[]interface{}{}, []interface{}{}, true, false, true, nil},
+		{[]interface{}{}, []interface{}{"a"}, true, false, false, nil},
+		{[]interface{}{"a"}, []interface{}{}, false, false, false, nil},
+		{[]interface{}{"a"}, []interface{}{"a", "b"}, true, true, false, nil},
+		{[]interface{}{"a", "b"}, []interface{}{"b", "a", "a"}, true, true, true, nil},
+		{[]interface{}{"a", "c"}, []interface{}{"a", "b"}, false, true, false, nil},
+		{[]interface{}{"c"}, []interface{}{"a", "b"}, false, false, false, nil},
+		{[]interface{}{uint(1), int64(2)}, []interface{}{2.0, 1}, true, true, true, nil},
+		{[]interface{}{"a"}, []interface{}{"a", 1}, false, false, false, errors.New("")},
+	}
+	for _, d := range data {
+		t.Run(fmt.Sprintf("%v, %v", d.left, d.right), func(t *testing.T) {
+			gotSubset, err := isSubset(d.left, d.right)
+			if err != nil || d.expectError != nil {
+				if err == nil {
+					t.Fatalf("expected error: %v", d.expectError)
+				} else if d.expectError == nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if gotSubset != d.wantSubset {
+				t.Fatalf("isSubset: got %v, want %v", gotSubset, d.wantSubset)
+			}
+
+			gotIntersect, err := intersects(d.left, d.right)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if gotIntersect != d.wantIntersect {
+				t.Fatalf("intersects: got %v, want %v", gotIntersect, d.wantIntersect)
+			}
+
+			gotEqual, err := equalSets(d.left, d.right)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if gotEqual != d.wantEqual {
+				t.Fatalf("equalSets: got %v, want %v", gotEqual, d.wantEqual)
+			}
+		})
+	}
+}
@@ -1,17 +1,26 @@
 package authz
 
 import (
+	"cmp"
 	"errors"
 	"fmt"
+	"math"
+	"net"
+	"net/netip"
 	"reflect"
+	"slices"
+	"time"
 )
 
-// Evaluate the truthy-ness of a value.
+// Evaluate the truthy-ness of a value. Null is falsy, so that a missing
+// optional reference may be used directly as a condition.
 func truthy(v interface{}) (bool, error) {
-	if vStr, err := coerceStr(v); err == nil {
+	if isNil(v) {
+		return false, nil
+	} else if vStr, err := coerceStr(v); err == nil {
 		return vStr != "", nil
-	} else if vUint, err := coerceUint(v); err == nil {
-		return vUint > 0, nil
+	} else if vNum, err := coerceNumber(v); err == nil {
+		return !vNum.isZero(), nil
 	} else if vBool, err := coerceBool(v); err == nil {
 		return vBool, nil
 	} else {
@@ -19,55 +28,179 @@ func truthy(v interface{}) (bool, error) {
 	}
 }
 
-// Attempt to coerce a value to a string.
+// Compare two values for equality. Both values must coerce to the same type,
+// except that numbers of any kind may be compared; see compareNumbers. Null
+// may be compared with a value of any type, and is equal only to null.
+func compareEqual(left, right interface{}) (bool, error) {
+	if isNil(left) || isNil(right) {
+		return isNil(left) && isNil(right), nil
+	}
+
+	asStr, err := coerceStr(left)
+	if err == nil {
+		rAsStr, err := coerceStr(right)
+		if err == nil {
+			return asStr == rAsStr, nil
+		} else {
+			return false, fmt.Errorf("mismatched types in equality comparison: %T != %T", left, right)
+		}
+	}
+
+	asNum, err := coerceNumber(left)
+	if err == nil {
+		rAsNum, err := coerceNumber(right)
+		if err == nil {
+			c, err := compareNumbers(asNum, rAsNum)
+			if err != nil {
+				// NaN is not equal to anything, including itself
+				return false, nil
+			}
+			return c == 0, nil
+		} else {
+			return false, fmt.Errorf("mismatched types in equality comparison: %T != %T", left, right)
+		}
+	}
+
+	asBool, err := coerceBool(left)
+	if err == nil {
+		rAsBool, err := coerceBool(right)
+		if err == nil {
+			return asBool == rAsBool, nil
+		} else {
+			return false, fmt.Errorf("mismatched types in equality comparison: %T != %T", left, right)
+		}
+	}
+
+	asTime, err := coerceTime(left)
+	if err == nil {
+		rAsTime, err := coerceTime(right)
+		if err == nil {
+			return asTime.Equal(rAsTime), nil
+		} else {
+			return false, fmt.Errorf("mismatched types in equality comparison: %T != %T", left, right)
+		}
+	}
+
+	asDuration, err := coerceDuration(left)
+	if err == nil {
+		rAsDuration, err := coerceDuration(right)
+		if err == nil {
+			return asDuration == rAsDuration, nil
+		} else {
+			return false, fmt.Errorf("mismatched types in equality comparison: %T != %T", left, right)
+		}
+	}
+
+	return false, fmt.Errorf("unsupported type in equality comparison: %T", left)
+}
+
+// Compare two values that admit an ordering, returning -1, 0, or +1.
+func compareOrdered(left, right interface{}) (int, error) {
+	if lStr, err := coerceStr(left); err == nil {
+		rStr, err := coerceStr(right)
+		if err != nil {
+			return 0, fmt.Errorf("mismatched types in ordering comparison: %T, %T", left, right)
+		}
+		return cmp.Compare(lStr, rStr), nil
+	}
+
+	if lNum, err := coerceNumber(left); err == nil {
+		rNum, err := coerceNumber(right)
+		if err != nil {
+			return 0, fmt.Errorf("mismatched types in ordering comparison: %T, %T", left, right)
+		}
+		return compareNumbers(lNum, rNum)
+	}
+
+	if lTime, err := coerceTime(left); err == nil {
+		rTime, err := coerceTime(right)
+		if err != nil {
+			return 0, fmt.Errorf("mismatched types in ordering comparison: %T, %T", left, right)
+		}
+		return lTime.Compare(rTime), nil
+	}
+
+	if lDuration, err := coerceDuration(left); err == nil {
+		rDuration, err := coerceDuration(right)
+		if err != nil {
+			return 0, fmt.Errorf("mismatched types in ordering comparison: %T, %T", left, right)
+		}
+		return cmp.Compare(lDuration, rDuration), nil
+	}
+
+	return 0, fmt.Errorf("unsupported type in ordering comparison: %T", left)
+}
+
+// Attempt to coerce a value to a string. Values of named string types,
+// e.g. `type Role string`, are accepted.
 func coerceStr(v interface{}) (string, error) {
 	if s, ok := v.(string); ok {
 		return s, nil
 	}
+	if rv := underlyingValue(v); rv.Kind() == reflect.String {
+		return rv.String(), nil
+	}
 	return "", fmt.Errorf("expected string, got %v", reflect.TypeOf(v))
 }
 
-// Attempt to coerce a value to a slice of string.
+// Attempt to coerce a value to a slice of string. Slices and arrays of any
+// string type are accepted.
 func coerceStrSlice(v interface{}) ([]string, error) {
 	if s, ok := v.([]string); ok {
 		return s, nil
 	}
-	return nil, errors.New("failed to coerce slice")
-
+	return coerceElements(v, isKind(reflect.String), coerceStr)
 }
 
-// Attempt to coerce a value to a uint.
+// Attempt to coerce a value to a uint. Integers of any kind are accepted if
+// they are in range; they are never truncated or wrapped around.
 func coerceUint(v interface{}) (uint, error) {
-	intHelper := func(v int) (uint, error) {
-		if v < 0 {
-			return 0, fmt.Errorf("expected uint, got negative int")
+	n, err := coerceNumber(v)
+	if err != nil || n.kind == floatNumber {
+		return 0, fmt.Errorf("expected uint, got %v", reflect.TypeOf(v))
+	}
+
+	switch {
+	case n.kind == intNumber && n.i < 0:
+		return 0, fmt.Errorf("expected uint, got negative int")
+	case n.kind == intNumber:
+		return uint(n.i), nil
+	case n.u > math.MaxUint:
+		return 0, errors.New("expected uint, got uint out of range")
+	default:
+		return uint(n.u), nil
+	}
+}
+
+// Attempt to coerce a value to an int64.
+func coerceInt(v interface{}) (int64, error) {
+	n, err := coerceNumber(v)
+	if err != nil || n.kind == floatNumber {
+		return 0, fmt.Errorf("expected int, got %v", reflect.TypeOf(v))
+	}
+	if n.kind == uintNumber {
+		if n.u > math.MaxInt64 {
+			return 0, errors.New("expected int, got uint out of range")
 		}
-		return uint(v), nil
+		return int64(n.u), nil
 	}
+	return n.i, nil
+}
 
-	switch v := v.(type) {
-	case uint:
-		return v, nil
-	case uint8:
-		return uint(v), nil
-	case uint16:
-		return uint(v), nil
-	case uint32:
-		return uint(v), nil
-	case uint64:
-		return uint(v), nil
-	case int:
-		return intHelper(v)
-	case int8:
-		return intHelper(int(v))
-	case int16:
-		return intHelper(int(v))
-	case int32:
-		return intHelper(int(v))
-	case int64:
-		return intHelper(int(v))
+// Attempt to coerce a value to a float64. Integers are converted to the
+// nearest representable float.
+func coerceFloat(v interface{}) (float64, error) {
+	n, err := coerceNumber(v)
+	if err != nil {
+		return 0, fmt.Errorf("expected float, got %v", reflect.TypeOf(v))
+	}
+	switch n.kind {
+	case uintNumber:
+		return float64(n.u), nil
+	case intNumber:
+		return float64(n.i), nil
 	default:
-		return 0, fmt.Errorf("expected uint, got %v", reflect.TypeOf(v))
+		return n.f, nil
 	}
 }
 
@@ -95,24 +228,193 @@ func coerceUintSlice(v interface{}) ([]uint, error) {
 	case []int64:
 		return transformWithCheck(s, func(x int64) uint { return uint(x) }, func(x int64) bool { return x >= 0 })
 	default:
-		return nil, errors.New("failed to coerce slice")
+		return coerceElements(v, isKind(append(uintKinds, intKinds...)...), coerceUint)
+	}
+}
+
+// Attempt to coerce a value to a time. Non-nil pointers are dereferenced.
+func coerceTime(v interface{}) (time.Time, error) {
+	switch v := v.(type) {
+	case time.Time:
+		return v, nil
+	case *time.Time:
+		if v != nil {
+			return *v, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("expected time, got %v", reflect.TypeOf(v))
+}
+
+// Attempt to coerce a value to a duration. Non-nil pointers are dereferenced.
+func coerceDuration(v interface{}) (time.Duration, error) {
+	switch v := v.(type) {
+	case time.Duration:
+		return v, nil
+	case *time.Duration:
+		if v != nil {
+			return *v, nil
+		}
 	}
+	return 0, fmt.Errorf("expected duration, got %v", reflect.TypeOf(v))
 }
 
-// Attempt to coerce a value to a bool.
+// Attempt to coerce a value to an IP address. Strings are parsed, and
+// IPv4-mapped IPv6 addresses are unmapped.
+func coerceAddr(v interface{}) (netip.Addr, error) {
+	switch v := v.(type) {
+	case netip.Addr:
+		return v.Unmap(), nil
+	case net.IP:
+		addr, ok := netip.AddrFromSlice(v)
+		if !ok {
+			return netip.Addr{}, errors.New("invalid IP address")
+		}
+		return addr.Unmap(), nil
+	}
+
+	str, err := coerceStr(v)
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("expected IP address, got %v", reflect.TypeOf(v))
+	}
+	addr, err := netip.ParseAddr(str)
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("invalid IP address: %w", err)
+	}
+	return addr.Unmap(), nil
+}
+
+// Attempt to coerce a value to a bool. Values of named bool types are
+// accepted.
 func coerceBool(v interface{}) (bool, error) {
 	if b, ok := v.(bool); ok {
 		return b, nil
 	}
+	if rv := underlyingValue(v); rv.Kind() == reflect.Bool {
+		return rv.Bool(), nil
+	}
 	return false, fmt.Errorf("expected bool, got %v", reflect.TypeOf(v))
 }
 
-// Attempt to coerce a value to a slice of bool.
+// Attempt to coerce a value to a slice of bool. Slices and arrays of any bool
+// type are accepted.
 func coerceBoolSlice(v interface{}) ([]bool, error) {
 	if s, ok := v.([]bool); ok {
 		return s, nil
 	}
-	return nil, errors.New("failed to coerce slice")
+	return coerceElements(v, isKind(reflect.Bool), coerceBool)
+}
+
+// Attempt to coerce a value to a collection of elements. Slices and arrays
+// of any element type are accepted, as are maps, whose elements are their
+// keys; a map is thus a set, e.g. `map[string]struct{}`.
+func coerceCollection(v interface{}) ([]interface{}, error) {
+	if s, ok := v.([]interface{}); ok {
+		return s, nil
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		result := make([]interface{}, 0, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			result = append(result, rv.Index(i).Interface())
+		}
+		return result, nil
+	case reflect.Map:
+		result := make([]interface{}, 0, rv.Len())
+		for _, key := range rv.MapKeys() {
+			result = append(result, key.Interface())
+		}
+		return result, nil
+	default:
+		return nil, fmt.Errorf("expected collection, got %v", reflect.TypeOf(v))
+	}
+}
+
+// Attempt to coerce a value to a map key of the given type. Only string, bool
+// and integer key types are supported, for which a key equal to the value by
+// compareEqual is identical to the coerced value.
+func coerceKey(v interface{}, t reflect.Type) (reflect.Value, bool) {
+	key := reflect.New(t).Elem()
+	switch {
+	case isKind(reflect.String)(t):
+		s, err := coerceStr(v)
+		if err != nil {
+			return reflect.Value{}, false
+		}
+		key.SetString(s)
+	case isKind(reflect.Bool)(t):
+		b, err := coerceBool(v)
+		if err != nil {
+			return reflect.Value{}, false
+		}
+		key.SetBool(b)
+	case isKind(intKinds...)(t):
+		i, err := coerceInt(v)
+		if err != nil || key.OverflowInt(i) {
+			return reflect.Value{}, false
+		}
+		key.SetInt(i)
+	case isKind(uintKinds...)(t):
+		u, err := coerceUint(v)
+		if err != nil || key.OverflowUint(uint64(u)) {
+			return reflect.Value{}, false
+		}
+		key.SetUint(uint64(u))
+	default:
+		return reflect.Value{}, false
+	}
+	return key, true
+}
+
+// The kinds of unsigned and signed integer types.
+var (
+	uintKinds = []reflect.Kind{reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64}
+	intKinds  = []reflect.Kind{reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64}
+)
+
+// The type of time.Duration, which is an int64 but is never coerced to a number.
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Reflect a value so that it may be coerced according to its underlying
+// kind, e.g. a value of `type Role string` as a string. Durations are
+// distinct from numbers, so the returned value is invalid for a duration.
+func underlyingValue(v interface{}) reflect.Value {
+	rv := reflect.ValueOf(v)
+	if rv.IsValid() && rv.Type() == durationType {
+		return reflect.Value{}
+	}
+	return rv
+}
+
+// Get a predicate that holds for types with any of the given kinds, other
+// than time.Duration.
+func isKind(kinds ...reflect.Kind) func(reflect.Type) bool {
+	return func(t reflect.Type) bool {
+		return t != durationType && slices.Contains(kinds, t.Kind())
+	}
+}
+
+// Coerce each element of a slice or array whose element type satisfies the
+// given predicate.
+func coerceElements[T any](v interface{}, elemOk func(reflect.Type) bool, coerce func(interface{}) (T, error)) ([]T, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, errors.New("failed to coerce slice")
+	}
+	if !elemOk(rv.Type().Elem()) {
+		return nil, errors.New("failed to coerce slice")
+	}
+
+	result := make([]T, 0, rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		element, err := coerce(rv.Index(i).Interface())
+		if err != nil {
+			return nil, fmt.Errorf("failed to coerce slice: %w", err)
+		}
+		result = append(result, element)
+	}
+	return result, nil
 }
 
 // Transform a slice.
@@ -3,7 +3,12 @@ package authz
 import (
 	"errors"
 	"fmt"
+	"math"
+	"net"
+	"net/netip"
+	"reflect"
 	"testing"
+	"time"
 )
 
 // Truth evalutation works as expected.
@@ -19,7 +24,16 @@ func TestTruthy(t *testing.T) {
 		{0, false, nil},
 		{true, true, nil},
 		{false, false, nil},
-		{nil, false, errors.New("")},
+		{int64(-1), true, nil},
+		{0.5, true, nil},
+		{0.0, false, nil},
+		{nil, false, nil},
+		{(*string)(nil), false, nil},
+		{[]string(nil), false, errors.New("")},
+		{[]string{}, false, errors.New("")},
+		{testRole(""), false, nil},
+		{testLevel(3), true, nil},
+		{testFlag(true), true, nil},
 	}
 	for _, d := range data {
 		t.Run(fmt.Sprintf("%v", d.input), func(t *testing.T) {
@@ -58,6 +72,10 @@ func TestCoerceStringSlice(t *testing.T) {
 		{[]string{"a", "b"}, []string{"a", "b"}, nil},
 		{[]interface{}{"a", 1}, nil, errors.New("")},
 		{[]int{1, 2}, nil, errors.New("")},
+		{testRoles{"admin", "viewer"}, []string{"admin", "viewer"}, nil},
+		{[]testRole{"admin"}, []string{"admin"}, nil},
+		{[2]string{"a", "b"}, []string{"a", "b"}, nil},
+		{[]testLevel{1}, nil, errors.New("")},
 	}
 	for _, d := range data {
 		t.Run(fmt.Sprintf("%v", d.input), func(t *testing.T) {
@@ -97,6 +115,11 @@ func TestCoerceUintSlice(t *testing.T) {
 		{[]string{"a", "b"}, nil, errors.New("")},
 		{[]interface{}{"a", 1}, nil, errors.New("")},
 		{"hello", nil, errors.New("")},
+		{[]testLevel{1, 2}, []uint{1, 2}, nil},
+		{[3]uint16{1, 2, 3}, []uint{1, 2, 3}, nil},
+		{[]testLevel{1, -2}, nil, errors.New("")},
+		{[]time.Duration{time.Second}, nil, errors.New("")},
+		{testRoles{"admin"}, nil, errors.New("")},
 	}
 	for _, d := range data {
 		t.Run(fmt.Sprintf("%v", d.input), func(t *testing.T) {
@@ -133,6 +156,8 @@ func TestCoerceBoolSlice(t *testing.T) {
 	}{
 		{[]bool{true, false}, []bool{true, false}, nil},
 		{[]int{1, 2}, nil, errors.New("")},
+		{[]testFlag{true}, []bool{true}, nil},
+		{[1]bool{false}, []bool{false}, nil},
 		{[]string{"a", "b"}, nil, errors.New("")},
 	}
 	for _, d := range data {
@@ -160,3 +185,170 @@ func TestCoerceBoolSlice(t *testing.T) {
 		})
 	}
 }
+
+// Coercion to IP address works as expected.
+func TestCoerceAddr(t *testing.T) {
+	data := []struct {
+		input       interface{}
+		want        netip.Addr
+		expectError error
+	}{
+		{"10.0.0.1", netip.MustParseAddr("10.0.0.1"), nil},
+		{"::ffff:10.0.0.1", netip.MustParseAddr("10.0.0.1"), nil},
+		{"2001:db8::1", netip.MustParseAddr("2001:db8::1"), nil},
+		{net.ParseIP("10.0.0.1"), netip.MustParseAddr("10.0.0.1"), nil},
+		{netip.MustParseAddr("fd00::1"), netip.MustParseAddr("fd00::1"), nil},
+		{"10.0.0", netip.Addr{}, errors.New("")},
+		{42, netip.Addr{}, errors.New("")},
+	}
+	for _, d := range data {
+		t.Run(fmt.Sprintf("%v", d.input), func(t *testing.T) {
+			got, err := coerceAddr(d.input)
+			if err != nil {
+				if d.expectError == nil {
+					t.Fatalf("unexpected error: %v", err)
+				} else {
+					return
+				}
+			}
+
+			if d.expectError != nil {
+				if err == nil {
+					t.Fatalf("expected error: %v", d.expectError)
+				} else {
+					return
+				}
+			}
+
+			if got != d.want {
+				t.Fatalf("got %v, want %v", got, d.want)
+			}
+		})
+	}
+}
+
+// Coercion to signed and floating point numbers works as expected.
+func TestCoerceIntFloat(t *testing.T) {
+	data := []struct {
+		input          interface{}
+		wantInt        int64
+		expectIntError error
+		wantFloat      float64
+	}{
+		{-1, -1, nil, -1},
+		{int8(-8), -8, nil, -8},
+		{uint(42), 42, nil, 42},
+		{uint64(math.MaxUint64), 0, errors.New(""), math.MaxUint64},
+		{2.5, 0, errors.New(""), 2.5},
+		{float32(0.5), 0, errors.New(""), 0.5},
+	}
+	for _, d := range data {
+		t.Run(fmt.Sprintf("%v", d.input), func(t *testing.T) {
+			gotInt, err := coerceInt(d.input)
+			if err != nil && d.expectIntError == nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err == nil && d.expectIntError != nil {
+				t.Fatalf("expected error: %v", d.expectIntError)
+			}
+			if err == nil && gotInt != d.wantInt {
+				t.Fatalf("got %v, want %v", gotInt, d.wantInt)
+			}
+
+			gotFloat, err := coerceFloat(d.input)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if gotFloat != d.wantFloat {
+				t.Fatalf("got %v, want %v", gotFloat, d.wantFloat)
+			}
+		})
+	}
+
+	if _, err := coerceFloat("1.5"); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+type testRole string
+type testRoles []testRole
+type testLevel int
+type testFlag bool
+type testAlias = string
+
+// Coercion accepts values of named types by their underlying kind.
+func TestCoerceNamedTypes(t *testing.T) {
+	if got, err := coerceStr(testRole("admin")); err != nil || got != "admin" {
+		t.Fatalf("got %v, %v", got, err)
+	}
+	if got, err := coerceStr(testAlias("admin")); err != nil || got != "admin" {
+		t.Fatalf("got %v, %v", got, err)
+	}
+	if got, err := coerceUint(testLevel(3)); err != nil || got != 3 {
+		t.Fatalf("got %v, %v", got, err)
+	}
+	if _, err := coerceUint(testLevel(-3)); err == nil {
+		t.Fatalf("expected error")
+	}
+	if _, err := coerceUint(1.0); err == nil {
+		t.Fatalf("expected error")
+	}
+	if got, err := coerceInt(testLevel(-3)); err != nil || got != -3 {
+		t.Fatalf("got %v, %v", got, err)
+	}
+	if got, err := coerceBool(testFlag(true)); err != nil || !got {
+		t.Fatalf("got %v, %v", got, err)
+	}
+	if got, err := coerceAddr(testRole("10.0.0.1")); err != nil || got != netip.MustParseAddr("10.0.0.1") {
+		t.Fatalf("got %v, %v", got, err)
+	}
+
+	// Durations are not numbers
+	if _, err := coerceNumber(time.Second); err == nil {
+		t.Fatalf("expected error")
+	}
+	if _, err := coerceUint(time.Second); err == nil {
+		t.Fatalf("expected error")
+	}
+	if got, err := coerceDuration(time.Second); err != nil || got != time.Second {
+		t.Fatalf("got %v, %v", got, err)
+	}
+}
+
+// Coercion to a collection accepts slices, arrays and maps.
+func TestCoerceCollection(t *testing.T) {
+	data := []struct {
+		input       interface{}
+		want        []interface{}
+		expectError error
+	}{
+		{[]interface{}{"a", 1}, []interface{}{"a", 1}, nil},
+		{[]string{"a", "b"}, []interface{}{"a", "b"}, nil},
+		{[2]uint{1, 2}, []interface{}{uint(1), uint(2)}, nil},
+		{testRoles{"admin"}, []interface{}{testRole("admin")}, nil},
+		{map[string]struct{}{"a": {}}, []interface{}{"a"}, nil},
+		{[]int(nil), []interface{}{}, nil},
+		{"hello", nil, errors.New("")},
+		{nil, nil, errors.New("")},
+	}
+	for _, d := range data {
+		t.Run(fmt.Sprintf("%v", d.input), func(t *testing.T) {
+			got, err := coerceCollection(d.input)
+			if err != nil {
+				if d.expectError == nil {
+					t.Fatalf("unexpected error: %v", err)
+				} else {
+					return
+				}
+			}
+
+			if d.expectError != nil {
+				t.Fatalf("expected error: %v", d.expectError)
+			}
+
+			if !reflect.DeepEqual(got, d.want) {
+				t.Fatalf("got %v, want %v", got, d.want)
+			}
+		})
+	}
+}